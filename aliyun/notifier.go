@@ -0,0 +1,38 @@
+package aliyun
+
+import (
+	"github.com/smileboywtu/CoinNotify/notify"
+)
+
+// Notifier send alert as aliyun sms
+type Notifier struct {
+	Opts AliyunSMSOpt
+}
+
+func NewNotifier(opts AliyunSMSOpt) *Notifier {
+	return &Notifier{Opts: opts}
+}
+
+// Notify send the alert, the sms template has no slot for the reason so it
+// is dropped. Warnings go through the warning template, and are skipped
+// when there is none as the price template would render blanks
+func (n *Notifier) Notify(alert notify.Alert) error {
+	if alert.Warning() {
+		if n.Opts.WarnTemplateCode == "" {
+			return nil
+		}
+		opts := n.Opts
+		opts.TemplateCode = opts.WarnTemplateCode
+		return SendSMS(opts, SMSContentCtx{
+			Platform: alert.Platform,
+			CoinType: alert.CoinType,
+			Reason:   alert.Reason,
+		})
+	}
+	return SendSMS(n.Opts, SMSContentCtx{
+		Platform: alert.Platform,
+		CoinType: alert.CoinType,
+		Price:    alert.Price,
+		Percent:  alert.Percent,
+	})
+}
@@ -15,14 +15,18 @@ type AliyunSMSOpt struct {
 
 	SignName     string
 	TemplateCode string
-	NotifyPhone  string
+	// WarnTemplateCode is the template of warnings, its slots are platform,
+	// cointype and reason. Warnings are not sent without it
+	WarnTemplateCode string
+	NotifyPhone      string
 }
 
 type SMSContentCtx struct {
 	Platform string `json:"platform"`
 	CoinType string `json:"cointype"`
-	Price    string `json:"price"`
-	Percent  string `json:"percent"`
+	Price    string `json:"price,omitempty"`
+	Percent  string `json:"percent,omitempty"`
+	Reason   string `json:"reason,omitempty"`
 }
 
 func SendSMS(opts AliyunSMSOpt, context SMSContentCtx) error {
@@ -32,9 +36,9 @@ func SendSMS(opts AliyunSMSOpt, context SMSContentCtx) error {
 
 	params, err := json.Marshal(context)
 	//respSendSms, err := dysms.SendSms(uuid.New(), phone, "", "SMS_135043012", string(params)).DoActionWithException()
-	respSendSms, err := dysms.SendSms(uuid.New(), opts.NotifyPhone, opts.SignName, opts.TemplateCode, string(params)).DoActionWithException()
+	_, err = dysms.SendSms(uuid.New(), opts.NotifyPhone, opts.SignName, opts.TemplateCode, string(params)).DoActionWithException()
 	if err != nil {
-		return errors.New(fmt.Sprintf("send sms failed", err, respSendSms.Error()))
+		return errors.New(fmt.Sprintf("send sms failed: %s", err))
 	}
 	return nil
 }
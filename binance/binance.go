@@ -0,0 +1,251 @@
+// Package binance read coin price from a binance compatible exchange rest api
+package binance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+)
+
+const (
+	DefaultBaseURL  = "https://api.binance.com"
+	DefaultPlatform = "Binance"
+
+	tickerPath       = "/api/v3/ticker/24hr"
+	exchangeInfoPath = "/api/v3/exchangeInfo"
+
+	// marketsTTL is how long the market list is kept before it is read again
+	marketsTTL = time.Hour
+)
+
+// DefaultQuotes is the quote asset preference when a coin trade in several markets
+var DefaultQuotes = []string{"USDT", "BTC", "ETH", "BNB"}
+
+// Ticker is one entry of the 24hr ticker response
+type Ticker struct {
+	Symbol             string `json:"symbol"`
+	PriceChangePercent string `json:"priceChangePercent"`
+	LastPrice          string `json:"lastPrice"`
+	Volume             string `json:"volume"`
+	QuoteVolume        string `json:"quoteVolume"`
+}
+
+type BinanceOpt struct {
+	BaseURL string
+
+	// Platform is the exchange name set in CoinPriceMeta
+	Platform string
+
+	// Quotes is the quote assets in preference order
+	Quotes  []string
+	Timeout time.Duration
+}
+
+// Market is one trading pair of the exchange info
+type Market struct {
+	Symbol     string `json:"symbol"`
+	Status     string `json:"status"`
+	BaseAsset  string `json:"baseAsset"`
+	QuoteAsset string `json:"quoteAsset"`
+}
+
+// Source poll the 24hr ticker of the markets of the watched coins
+type Source struct {
+	opts   BinanceOpt
+	client *http.Client
+
+	// markets is the trading symbols, read again after marketsTTL
+	lock      sync.Mutex
+	markets   map[string]bool
+	marketsAt time.Time
+}
+
+func NewSource(opts BinanceOpt) *Source {
+	if opts.BaseURL == "" {
+		opts.BaseURL = DefaultBaseURL
+	}
+	if opts.Platform == "" {
+		opts.Platform = DefaultPlatform
+	}
+	if len(opts.Quotes) == 0 {
+		opts.Quotes = DefaultQuotes
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 15 * time.Second
+	}
+	return &Source{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+	}
+}
+
+func (s *Source) Name() string {
+	return strings.ToLower(s.opts.Platform)
+}
+
+// Tickers download the 24hr ticker of symbols, or of every market when
+// symbols is empty which cost much more request weight
+func (s *Source) Tickers(symbols ...string) ([]Ticker, error) {
+	address := strings.TrimRight(s.opts.BaseURL, "/") + tickerPath
+	if len(symbols) > 0 {
+		list, _ := json.Marshal(symbols)
+		address += "?symbols=" + url.QueryEscape(string(list))
+	}
+
+	response, err := s.client.Get(address)
+	if err != nil {
+		return nil, fmt.Errorf("%s ticker http error: %s", s.Name(), err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s ticker http error: %s", s.Name(), response.Status)
+	}
+
+	var tickers []Ticker
+	if err := json.NewDecoder(response.Body).Decode(&tickers); err != nil {
+		return nil, fmt.Errorf("%s ticker decode error: %s", s.Name(), err)
+	}
+	return tickers, nil
+}
+
+// Markets return the symbols that trade now, the list is cached for an hour
+func (s *Source) Markets() (map[string]bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.markets != nil && time.Since(s.marketsAt) < marketsTTL {
+		return s.markets, nil
+	}
+
+	response, err := s.client.Get(strings.TrimRight(s.opts.BaseURL, "/") + exchangeInfoPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s exchange info http error: %s", s.Name(), err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s exchange info http error: %s", s.Name(), response.Status)
+	}
+
+	var info struct {
+		Symbols []Market `json:"symbols"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("%s exchange info decode error: %s", s.Name(), err)
+	}
+
+	markets := make(map[string]bool, len(info.Symbols))
+	for _, market := range info.Symbols {
+		if market.Status == "" || market.Status == "TRADING" {
+			markets[market.Symbol] = true
+		}
+	}
+	s.markets, s.marketsAt = markets, time.Now()
+	return markets, nil
+}
+
+// SplitSymbol split a market symbol like CMTBTC into base and quote
+func SplitSymbol(symbol string, quotes []string) (string, string, bool) {
+	for _, quote := range quotes {
+		if len(symbol) > len(quote) && strings.HasSuffix(symbol, quote) {
+			return strings.TrimSuffix(symbol, quote), quote, true
+		}
+	}
+	return "", "", false
+}
+
+// Fetch return one record per watched coin, taken from the most preferred
+// quote market. Aliases and @platform selectors are resolved like on the
+// userticker page. The coins without market are reported by a
+// *feixiaohao.NotWatchedError with the records of the others
+func (s *Source) Fetch(filter feixiaohao.CoinFilter) ([]feixiaohao.CoinPriceMeta, error) {
+	if len(filter.CoinType) == 0 {
+		return nil, fmt.Errorf("%s can not watch every coin, set the cointype", s.Name())
+	}
+	metas, err := s.fetch(filter.Symbols())
+	if err != nil {
+		return nil, err
+	}
+	metas = feixiaohao.SelectRecords(filter, metas)
+	return metas, feixiaohao.Missing(s.Name(), filter, metas)
+}
+
+// fetch return one record per coin symbol, taken from the most preferred quote market
+func (s *Source) fetch(coins []string) ([]feixiaohao.CoinPriceMeta, error) {
+	markets, err := s.Markets()
+	if err != nil {
+		return nil, err
+	}
+
+	// ask only the preferred market of each coin
+	symbols := make([]string, 0, len(coins))
+	for _, coin := range coins {
+		for _, quote := range s.opts.Quotes {
+			if symbol := strings.ToUpper(coin) + quote; markets[symbol] {
+				symbols = append(symbols, symbol)
+				break
+			}
+		}
+	}
+	if len(symbols) == 0 {
+		return []feixiaohao.CoinPriceMeta{}, nil
+	}
+
+	tickers, err := s.Tickers(symbols...)
+	if err != nil {
+		return nil, err
+	}
+
+	watched := make(map[string]bool, len(coins))
+	for _, coin := range coins {
+		watched[strings.ToUpper(coin)] = true
+	}
+
+	rank := make(map[string]int, len(s.opts.Quotes))
+	for i, quote := range s.opts.Quotes {
+		rank[quote] = i
+	}
+
+	best := make(map[string]Ticker)
+	bestQuote := make(map[string]string)
+	for _, ticker := range tickers {
+		base, quote, ok := SplitSymbol(ticker.Symbol, s.opts.Quotes)
+		if !ok || !watched[base] {
+			continue
+		}
+		if current, ok := bestQuote[base]; ok && rank[current] <= rank[quote] {
+			continue
+		}
+		best[base] = ticker
+		bestQuote[base] = quote
+	}
+
+	metas := make([]feixiaohao.CoinPriceMeta, 0, len(best))
+	for _, coin := range coins {
+		base := strings.ToUpper(coin)
+		ticker, ok := best[base]
+		if !ok {
+			continue
+		}
+		metas = append(metas, feixiaohao.CoinPriceMeta{
+			Platform: s.opts.Platform,
+			Price:    ticker.LastPrice + " " + bestQuote[base],
+			Percent:  ticker.PriceChangePercent + "%",
+			CoinType: base,
+			Volume:   volume(ticker.QuoteVolume, bestQuote[base]),
+		})
+	}
+	return metas, nil
+}
+
+// volume tag the quote volume with its currency, empty when unknown
+func volume(quoteVolume string, quote string) string {
+	if quoteVolume == "" {
+		return ""
+	}
+	return quoteVolume + " " + quote
+}
@@ -0,0 +1,90 @@
+package binance
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+)
+
+func TestSplitSymbol(t *testing.T) {
+	cases := []struct {
+		symbol, base, quote string
+	}{
+		{"CMTBTC", "CMT", "BTC"},
+		{"IOSTUSDT", "IOST", "USDT"},
+		{"ETHBTC", "ETH", "BTC"},
+	}
+	for _, c := range cases {
+		base, quote, ok := SplitSymbol(c.symbol, DefaultQuotes)
+		if !ok || base != c.base || quote != c.quote {
+			t.Errorf("%s: got %s %s", c.symbol, base, quote)
+		}
+	}
+	if _, _, ok := SplitSymbol("USDT", DefaultQuotes); ok {
+		t.Error("quote only symbol should not split")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	exchange := NewFakeExchange(
+		Ticker{Symbol: "CMTBTC", LastPrice: "0.00001234", PriceChangePercent: "5.20"},
+		Ticker{Symbol: "IOSTBTC", LastPrice: "0.00000300", PriceChangePercent: "-1.00"},
+		Ticker{Symbol: "IOSTUSDT", LastPrice: "0.02100000", PriceChangePercent: "-3.10", QuoteVolume: "150000.5"},
+		Ticker{Symbol: "ETHBTC", LastPrice: "0.07", PriceChangePercent: "0.50"},
+	)
+	defer exchange.Close()
+
+	source := NewSource(BinanceOpt{BaseURL: exchange.URL()})
+	filter := feixiaohao.CoinFilter{CoinType: []string{"CMT", "iost"}}
+
+	metas, err := source.Fetch(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []feixiaohao.CoinPriceMeta{
+		{Platform: "Binance", Price: "0.00001234 BTC", Percent: "5.20%", CoinType: "CMT"},
+		{Platform: "Binance", Price: "0.02100000 USDT", Percent: "-3.10%", CoinType: "IOST", Volume: "150000.5 USDT"},
+	}
+	if len(metas) != len(expect) {
+		t.Fatal("bad metas: ", metas)
+	}
+	for i := range expect {
+		if metas[i] != expect[i] {
+			t.Fatal("expect ", expect[i], " got ", metas[i])
+		}
+	}
+
+	for _, request := range exchange.Requests() {
+		if request == tickerPath {
+			t.Fatal("the ticker of every market should not be downloaded")
+		}
+	}
+	if last := exchange.Requests()[len(exchange.Requests())-1]; !strings.Contains(last, "symbols=") || !strings.Contains(last, "IOSTUSDT") || strings.Contains(last, "IOSTBTC") {
+		t.Fatal("only the preferred markets should be asked: ", last)
+	}
+
+	// aliases and platform selectors resolve like on the userticker page
+	aliased := feixiaohao.CoinFilter{CoinType: []string{"以太坊", "CMT@Binance", "IOST@Huobi"}}
+	metas, err = source.Fetch(aliased)
+	if !feixiaohao.NotWatched(err) || !strings.Contains(err.Error(), "IOST@Huobi") {
+		t.Fatal("a selector binance can not serve should be reported: ", err)
+	}
+	if len(metas) != 2 || metas[0].CoinType != "以太坊" || metas[0].Price != "0.07 BTC" || metas[1].CoinType != "CMT@Binance" {
+		t.Fatal("aliases and selectors should be resolved: ", metas)
+	}
+
+	unknown := feixiaohao.CoinFilter{CoinType: []string{"CMT", "NOPE"}}
+	metas, err = source.Fetch(unknown)
+	if !feixiaohao.NotWatched(err) || !strings.Contains(err.Error(), "NOPE") || len(metas) != 1 {
+		t.Fatal("a coin without market should be reported: ", metas, err)
+	}
+	if _, err := source.Fetch(feixiaohao.CoinFilter{}); err == nil {
+		t.Fatal("an empty filter should be refused")
+	}
+
+	exchange.FailNext(1)
+	if _, err := source.Fetch(filter); err == nil {
+		t.Fatal("server error should be reported")
+	}
+}
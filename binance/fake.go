@@ -0,0 +1,204 @@
+package binance
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// FakeExchange serve the 24hr ticker api from memory, so the binance
+// source can run offline in tests
+type FakeExchange struct {
+	Server *httptest.Server
+
+	lock    sync.Mutex
+	tickers  []Ticker
+	fail     int
+	requests []string
+}
+
+func NewFakeExchange(tickers ...Ticker) *FakeExchange {
+	exchange := &FakeExchange{tickers: tickers}
+	exchange.Server = httptest.NewServer(exchange)
+	return exchange
+}
+
+// URL is the base url to use in BinanceOpt
+func (f *FakeExchange) URL() string {
+	return f.Server.URL
+}
+
+func (f *FakeExchange) Close() {
+	f.Server.Close()
+}
+
+// SetTicker add or replace the ticker of one market
+func (f *FakeExchange) SetTicker(ticker Ticker) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for i := range f.tickers {
+		if f.tickers[i].Symbol == ticker.Symbol {
+			f.tickers[i] = ticker
+			return
+		}
+	}
+	f.tickers = append(f.tickers, ticker)
+}
+
+// Requests return the path and query of every request served
+func (f *FakeExchange) Requests() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string{}, f.requests...)
+}
+
+// FailNext make the next n requests answer 500
+func (f *FakeExchange) FailNext(n int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.fail = n
+}
+
+func (f *FakeExchange) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.requests = append(f.requests, r.URL.RequestURI())
+	if r.URL.Path != tickerPath && r.URL.Path != exchangeInfoPath {
+		http.NotFound(w, r)
+		return
+	}
+	if f.fail > 0 {
+		f.fail--
+		http.Error(w, `{"code": -1000, "msg": "unknown error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == exchangeInfoPath {
+		markets := make([]Market, 0, len(f.tickers))
+		for _, ticker := range f.tickers {
+			base, quote, _ := SplitSymbol(ticker.Symbol, DefaultQuotes)
+			markets = append(markets, Market{Symbol: ticker.Symbol, Status: "TRADING", BaseAsset: base, QuoteAsset: quote})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"symbols": markets})
+		return
+	}
+	if list := r.URL.Query().Get("symbols"); list != "" {
+		var symbols []string
+		if err := json.Unmarshal([]byte(list), &symbols); err != nil {
+			http.Error(w, `{"code": -1100, "msg": "Illegal characters found in parameter 'symbols'."}`, http.StatusBadRequest)
+			return
+		}
+		tickers := make([]Ticker, 0, len(symbols))
+		for _, symbol := range symbols {
+			found := false
+			for _, ticker := range f.tickers {
+				if ticker.Symbol == symbol {
+					tickers = append(tickers, ticker)
+					found = true
+				}
+			}
+			if !found {
+				http.Error(w, `{"code": -1121, "msg": "Invalid symbol."}`, http.StatusBadRequest)
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(tickers)
+		return
+	}
+	if symbol := r.URL.Query().Get("symbol"); symbol != "" {
+		for _, ticker := range f.tickers {
+			if ticker.Symbol == symbol {
+				json.NewEncoder(w).Encode(ticker)
+				return
+			}
+		}
+		http.Error(w, `{"code": -1121, "msg": "Invalid symbol."}`, http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(f.tickers)
+}
+
+// FakeStream is a local stand-in of the ticker websocket stream
+type FakeStream struct {
+	Server *httptest.Server
+
+	// Subscribed get the stream names of every SUBSCRIBE request and
+	// Unsubscribed of every UNSUBSCRIBE one
+	Subscribed   chan []string
+	Unsubscribed chan []string
+
+	lock  sync.Mutex
+	conns []*websocket.Conn
+}
+
+func NewFakeStream() *FakeStream {
+	stream := &FakeStream{Subscribed: make(chan []string, 10), Unsubscribed: make(chan []string, 10)}
+	stream.Server = httptest.NewServer(websocket.Handler(stream.serve))
+	return stream
+}
+
+// URL is the ws:// url to use in StreamOpt
+func (f *FakeStream) URL() string {
+	return "ws" + strings.TrimPrefix(f.Server.URL, "http")
+}
+
+func (f *FakeStream) serve(conn *websocket.Conn) {
+	var request subscribeRequest
+	if err := websocket.JSON.Receive(conn, &request); err != nil {
+		return
+	}
+	f.lock.Lock()
+	f.conns = append(f.conns, conn)
+	f.lock.Unlock()
+
+	// answer the requests until the client or Drop close the connection
+	for {
+		websocket.JSON.Send(conn, map[string]interface{}{"result": nil, "id": request.ID})
+		if request.Method == "UNSUBSCRIBE" {
+			f.Unsubscribed <- request.Params
+		} else {
+			f.Subscribed <- request.Params
+		}
+		request = subscribeRequest{}
+		if err := websocket.JSON.Receive(conn, &request); err != nil {
+			return
+		}
+	}
+}
+
+// Push send a 24hrTicker event of ticker to every connection
+func (f *FakeStream) Push(ticker Ticker) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, conn := range f.conns {
+		websocket.JSON.Send(conn, tickerEvent{
+			Event:       "24hrTicker",
+			Symbol:      ticker.Symbol,
+			Percent:     ticker.PriceChangePercent,
+			LastPrice:   ticker.LastPrice,
+			Volume:      ticker.Volume,
+			QuoteVolume: ticker.QuoteVolume,
+		})
+	}
+}
+
+// Drop close every connection to force the client to reconnect
+func (f *FakeStream) Drop() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+	f.conns = nil
+}
+
+func (f *FakeStream) Close() {
+	f.Drop()
+	f.Server.Close()
+}
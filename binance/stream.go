@@ -0,0 +1,298 @@
+package binance
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+	"github.com/smileboywtu/CoinNotify/source"
+)
+
+const DefaultStreamURL = "wss://stream.binance.com:9443/ws"
+
+type StreamOpt struct {
+	URL      string
+	Platform string
+	Quotes   []string
+
+	// MinBackoff and MaxBackoff bound the wait before a reconnect
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// ReadTimeout close a connection that stay silent too long
+	ReadTimeout time.Duration
+}
+
+// tickerEvent is the 24hrTicker stream payload, fields that differ only
+// in case are all declared so encoding/json does not mix them up
+type tickerEvent struct {
+	Event       string `json:"e"`
+	EventTime   int64  `json:"E"`
+	Symbol      string `json:"s"`
+	PriceChange string `json:"p"`
+	Percent     string `json:"P"`
+	LastPrice   string `json:"c"`
+	CloseTime   int64  `json:"C"`
+	Volume      string `json:"v"`
+	QuoteVolume string `json:"q"`
+	LastQty     string `json:"Q"`
+}
+
+type subscribeRequest struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+	ID     int      `json:"id"`
+}
+
+// StreamSource subscribe the ticker stream of every watched market and
+// keep the latest price in a price book
+type StreamSource struct {
+	opts StreamOpt
+	book *source.PriceBook
+
+	lock      sync.Mutex
+	bestQuote map[string]string
+	requestID int
+
+	// filter is the watched coins, conn the running connection and
+	// subscribed its streams
+	filter     feixiaohao.CoinFilter
+	conn       *websocket.Conn
+	subscribed []string
+}
+
+func NewStreamSource(opts StreamOpt) *StreamSource {
+	if opts.URL == "" {
+		opts.URL = DefaultStreamURL
+	}
+	if opts.Platform == "" {
+		opts.Platform = DefaultPlatform
+	}
+	if len(opts.Quotes) == 0 {
+		opts.Quotes = DefaultQuotes
+	}
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = time.Second
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = time.Minute
+	}
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = 3 * time.Minute
+	}
+	return &StreamSource{
+		opts:      opts,
+		book:      source.NewPriceBook(),
+		bestQuote: make(map[string]string),
+	}
+}
+
+func (s *StreamSource) Name() string {
+	return strings.ToLower(s.opts.Platform) + " stream"
+}
+
+// Fetch return the latest streamed records, aliases and @platform
+// selectors are resolved like on the userticker page
+func (s *StreamSource) Fetch(filter feixiaohao.CoinFilter) ([]feixiaohao.CoinPriceMeta, error) {
+	symbols := filter
+	symbols.CoinType = filter.Symbols()
+	return feixiaohao.SelectRecords(filter, s.book.Records(symbols)), nil
+}
+
+// Streams return the stream names of every market of the watched coins
+func (s *StreamSource) Streams(filter feixiaohao.CoinFilter) []string {
+	coins := filter.Symbols()
+	streams := make([]string, 0, len(coins)*len(s.opts.Quotes))
+	for _, coin := range coins {
+		for _, quote := range s.opts.Quotes {
+			streams = append(streams, strings.ToLower(coin+quote)+"@ticker")
+		}
+	}
+	return streams
+}
+
+func (s *StreamSource) Stream(filter feixiaohao.CoinFilter, updates chan<- feixiaohao.CoinPriceMeta, quit <-chan struct{}, errc chan<- error) {
+	s.lock.Lock()
+	s.filter = filter
+	s.lock.Unlock()
+
+	backoff := s.opts.MinBackoff
+	for {
+		received, err := s.session(updates, quit)
+		select {
+		case <-quit:
+			return
+		default:
+		}
+
+		// a connection that worked start the backoff over
+		if received {
+			backoff = s.opts.MinBackoff
+		}
+		errc <- &source.DisconnectError{Source: s.Name(), Backoff: backoff, Err: err}
+
+		select {
+		case <-quit:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > s.opts.MaxBackoff {
+			backoff = s.opts.MaxBackoff
+		}
+	}
+}
+
+// Subscribe change the watched coins, the running connection subscribe the
+// new markets and unsubscribe the ones no longer watched
+func (s *StreamSource) Subscribe(filter feixiaohao.CoinFilter) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.filter = filter
+	if s.conn == nil {
+		return
+	}
+
+	streams := s.Streams(filter)
+	changes := []subscribeRequest{
+		{Method: "UNSUBSCRIBE", Params: missingStreams(streams, s.subscribed)},
+		{Method: "SUBSCRIBE", Params: missingStreams(s.subscribed, streams)},
+	}
+	for _, request := range changes {
+		if len(request.Params) == 0 {
+			continue
+		}
+		s.requestID++
+		request.ID = s.requestID
+		if err := websocket.JSON.Send(s.conn, request); err != nil {
+			// the session reconnect with the new filter
+			s.conn.Close()
+			return
+		}
+	}
+	s.subscribed = streams
+}
+
+// missingStreams return the streams of to that from does not have
+func missingStreams(from, to []string) []string {
+	have := make(map[string]bool, len(from))
+	for _, stream := range from {
+		have[stream] = true
+	}
+	missing := make([]string, 0)
+	for _, stream := range to {
+		if !have[stream] {
+			missing = append(missing, stream)
+		}
+	}
+	return missing
+}
+
+// watched return the filter of the running stream
+func (s *StreamSource) watched() feixiaohao.CoinFilter {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.filter
+}
+
+// session connect, subscribe and read until the connection fails
+func (s *StreamSource) session(updates chan<- feixiaohao.CoinPriceMeta, quit <-chan struct{}) (bool, error) {
+	conn, err := websocket.Dial(s.opts.URL, "", "http://localhost/")
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// unblock the read when quit
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-quit:
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	// subscribe under the lock so Subscribe diff against what was sent
+	s.lock.Lock()
+	s.requestID++
+	streams := s.Streams(s.filter)
+	err = websocket.JSON.Send(conn, subscribeRequest{Method: "SUBSCRIBE", Params: streams, ID: s.requestID})
+	if err == nil {
+		s.conn, s.subscribed = conn, streams
+	}
+	s.lock.Unlock()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		s.lock.Lock()
+		s.conn = nil
+		s.lock.Unlock()
+	}()
+
+	received := false
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.opts.ReadTimeout))
+		var event tickerEvent
+		if err := websocket.JSON.Receive(conn, &event); err != nil {
+			return received, err
+		}
+		received = true
+
+		// subscribe replies and other events
+		if event.Event != "24hrTicker" {
+			continue
+		}
+		meta, ok := s.update(event)
+		if !ok {
+			continue
+		}
+		for _, meta := range feixiaohao.SelectRecords(s.watched(), []feixiaohao.CoinPriceMeta{meta}) {
+			select {
+			case updates <- meta:
+			case <-quit:
+				return received, nil
+			}
+		}
+	}
+}
+
+// update record the event in the price book when it is from the most
+// preferred market seen for the coin
+func (s *StreamSource) update(event tickerEvent) (feixiaohao.CoinPriceMeta, bool) {
+	base, quote, ok := SplitSymbol(event.Symbol, s.opts.Quotes)
+	if !ok {
+		return feixiaohao.CoinPriceMeta{}, false
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if current, ok := s.bestQuote[base]; ok && quoteRank(s.opts.Quotes, current) < quoteRank(s.opts.Quotes, quote) {
+		return feixiaohao.CoinPriceMeta{}, false
+	}
+	s.bestQuote[base] = quote
+
+	meta := feixiaohao.CoinPriceMeta{
+		Platform: s.opts.Platform,
+		Price:    event.LastPrice + " " + quote,
+		Percent:  event.Percent + "%",
+		CoinType: base,
+		Volume:   volume(event.QuoteVolume, quote),
+	}
+	s.book.Update(meta)
+	return meta, true
+}
+
+func quoteRank(quotes []string, quote string) int {
+	for i, value := range quotes {
+		if value == quote {
+			return i
+		}
+	}
+	return len(quotes)
+}
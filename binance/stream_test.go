@@ -0,0 +1,111 @@
+package binance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+	"github.com/smileboywtu/CoinNotify/source"
+)
+
+func TestStream(t *testing.T) {
+	server := NewFakeStream()
+	defer server.Close()
+
+	stream := NewStreamSource(StreamOpt{
+		URL:        server.URL(),
+		Quotes:     []string{"USDT", "BTC"},
+		MinBackoff: 10 * time.Millisecond,
+	})
+	filter := feixiaohao.CoinFilter{CoinType: []string{"CMT"}}
+
+	updates := make(chan feixiaohao.CoinPriceMeta, 10)
+	errc := make(chan error, 10)
+	quit := make(chan struct{})
+	go stream.Stream(filter, updates, quit, errc)
+	defer close(quit)
+
+	wait := func() []string {
+		select {
+		case streams := <-server.Subscribed:
+			return streams
+		case <-time.After(5 * time.Second):
+			t.Fatal("no subscription")
+		}
+		return nil
+	}
+	receive := func() feixiaohao.CoinPriceMeta {
+		select {
+		case meta := <-updates:
+			return meta
+		case <-time.After(5 * time.Second):
+			t.Fatal("no update")
+		}
+		return feixiaohao.CoinPriceMeta{}
+	}
+
+	streams := wait()
+	if len(streams) != 2 || streams[0] != "cmtusdt@ticker" || streams[1] != "cmtbtc@ticker" {
+		t.Fatal("bad subscription: ", streams)
+	}
+
+	server.Push(Ticker{Symbol: "CMTBTC", LastPrice: "0.00001234", PriceChangePercent: "5.20"})
+	if meta := receive(); meta.Price != "0.00001234 BTC" || meta.Percent != "5.20%" || meta.Platform != "Binance" {
+		t.Fatal("bad update: ", meta)
+	}
+
+	// the USDT market is preferred once it is seen
+	server.Push(Ticker{Symbol: "CMTUSDT", LastPrice: "0.1", PriceChangePercent: "5.00"})
+	server.Push(Ticker{Symbol: "CMTBTC", LastPrice: "0.00001300", PriceChangePercent: "6.00"})
+	server.Push(Ticker{Symbol: "CMTUSDT", LastPrice: "0.2", PriceChangePercent: "7.00"})
+	if meta := receive(); meta.Price != "0.1 USDT" {
+		t.Fatal("bad update: ", meta)
+	}
+	if meta := receive(); meta.Price != "0.2 USDT" {
+		t.Fatal("BTC market should be skipped: ", meta)
+	}
+
+	// reconnect and subscribe again
+	server.Drop()
+	if streams := wait(); len(streams) != 2 {
+		t.Fatal("bad resubscription: ", streams)
+	}
+	select {
+	case err := <-errc:
+		if _, ok := err.(*source.DisconnectError); !ok {
+			t.Fatal("disconnect should be typed: ", err)
+		}
+	default:
+		t.Fatal("disconnect should be reported")
+	}
+
+	server.Push(Ticker{Symbol: "CMTUSDT", LastPrice: "0.3", PriceChangePercent: "8.00"})
+	if meta := receive(); meta.Price != "0.3 USDT" {
+		t.Fatal("bad update after reconnect: ", meta)
+	}
+
+	records, _ := stream.Fetch(filter)
+	if len(records) != 1 || records[0].Price != "0.3 USDT" {
+		t.Fatal("price book should hold the latest record: ", records)
+	}
+
+	// a reload change the subscriptions of the running connection
+	stream.Subscribe(feixiaohao.CoinFilter{CoinType: []string{"CMT", "IOST"}})
+	if streams := wait(); len(streams) != 2 || streams[0] != "iostusdt@ticker" || streams[1] != "iostbtc@ticker" {
+		t.Fatal("new coins should be subscribed: ", streams)
+	}
+	stream.Subscribe(feixiaohao.CoinFilter{CoinType: []string{"IOST"}})
+	select {
+	case streams := <-server.Unsubscribed:
+		if len(streams) != 2 || streams[0] != "cmtusdt@ticker" {
+			t.Fatal("bad unsubscription: ", streams)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("coins no longer watched should be unsubscribed")
+	}
+	server.Push(Ticker{Symbol: "CMTUSDT", LastPrice: "0.4", PriceChangePercent: "8.00"})
+	server.Push(Ticker{Symbol: "IOSTUSDT", LastPrice: "0.02", PriceChangePercent: "1.00"})
+	if meta := receive(); meta.CoinType != "IOST" {
+		t.Fatal("only the watched coins should be sent: ", meta)
+	}
+}
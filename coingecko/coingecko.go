@@ -0,0 +1,257 @@
+// Package coingecko read coin price from a coingecko style public api, no account needed
+package coingecko
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+)
+
+const (
+	DefaultBaseURL    = "https://api.coingecko.com/api/v3"
+	DefaultPlatform   = "CoinGecko"
+	DefaultVsCurrency = "usd"
+)
+
+type CoinGeckoOpt struct {
+	BaseURL  string
+	Platform string
+
+	// VsCurrency is the quote currency, like usd or cny
+	VsCurrency string
+
+	// IDs map symbols to provider ids, for symbols shared by several coins
+	IDs map[string]string
+
+	// APIKey is sent as x-cg-demo-api-key when set
+	APIKey  string
+	Timeout time.Duration
+}
+
+// Coin is one entry of the coin list
+type Coin struct {
+	ID     string `json:"id"`
+	Symbol string `json:"symbol"`
+	Name   string `json:"name"`
+}
+
+// Source fetch every watched coin in one batched call
+type Source struct {
+	opts   CoinGeckoOpt
+	client *http.Client
+
+	lock    sync.Mutex
+	symbols map[string][]Coin
+}
+
+func NewSource(opts CoinGeckoOpt) *Source {
+	if opts.BaseURL == "" {
+		opts.BaseURL = DefaultBaseURL
+	}
+	if opts.Platform == "" {
+		opts.Platform = DefaultPlatform
+	}
+	if opts.VsCurrency == "" {
+		opts.VsCurrency = DefaultVsCurrency
+	}
+	opts.VsCurrency = strings.ToLower(opts.VsCurrency)
+	// symbols are looked up upper case
+	ids := make(map[string]string, len(opts.IDs))
+	for symbol, id := range opts.IDs {
+		ids[strings.ToUpper(symbol)] = id
+	}
+	opts.IDs = ids
+	if opts.Timeout == 0 {
+		opts.Timeout = 15 * time.Second
+	}
+	return &Source{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+	}
+}
+
+func (s *Source) Name() string {
+	return strings.ToLower(s.opts.Platform)
+}
+
+func (s *Source) get(path string, query url.Values, result interface{}) error {
+	endpoint := strings.TrimRight(s.opts.BaseURL, "/") + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	request, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	if s.opts.APIKey != "" {
+		request.Header.Set("x-cg-demo-api-key", s.opts.APIKey)
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("%s http error: %s", s.Name(), err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s http error: %s", s.Name(), path, response.Status)
+	}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return fmt.Errorf("%s %s decode error: %s", s.Name(), path, err)
+	}
+	return nil
+}
+
+// loadCoins download the coin list once
+func (s *Source) loadCoins() (map[string][]Coin, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.symbols != nil {
+		return s.symbols, nil
+	}
+
+	var coins []Coin
+	if err := s.get("/coins/list", nil, &coins); err != nil {
+		return nil, err
+	}
+	symbols := make(map[string][]Coin)
+	for _, coin := range coins {
+		symbol := strings.ToUpper(coin.Symbol)
+		symbols[symbol] = append(symbols[symbol], coin)
+	}
+	s.symbols = symbols
+	return symbols, nil
+}
+
+// Resolve map every symbol to its provider id. The unknown symbols are
+// reported by a *feixiaohao.NotWatchedError and the ambiguous ones by a
+// *feixiaohao.AmbiguousError, with the ids of the others
+func (s *Source) Resolve(symbols []string) (map[string]string, error) {
+	ids := make(map[string]string, len(symbols))
+	var unresolved []string
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		if id, ok := s.opts.IDs[symbol]; ok {
+			ids[symbol] = id
+			continue
+		}
+		unresolved = append(unresolved, symbol)
+	}
+	if len(unresolved) == 0 {
+		return ids, nil
+	}
+
+	coins, err := s.loadCoins()
+	if err != nil {
+		return nil, err
+	}
+	var result error
+	var unknown []string
+	for _, symbol := range unresolved {
+		candidates := coins[symbol]
+		switch len(candidates) {
+		case 0:
+			unknown = append(unknown, symbol)
+		case 1:
+			ids[symbol] = candidates[0].ID
+		default:
+			// prefer the coin whose id is its own name, like bitcoin for BTC
+			id := ""
+			for _, coin := range candidates {
+				if strings.EqualFold(coin.ID, coin.Name) {
+					if id != "" {
+						id = ""
+						break
+					}
+					id = coin.ID
+				}
+			}
+			if id == "" {
+				names := make([]string, 0, len(candidates))
+				for _, coin := range candidates {
+					names = append(names, coin.ID)
+				}
+				sort.Strings(names)
+				result = multierror.Append(result, fmt.Errorf("%s: %w, set one of them in the id map", s.Name(), &feixiaohao.AmbiguousError{Entry: symbol, Rows: names}))
+				continue
+			}
+			ids[symbol] = id
+		}
+	}
+	if len(unknown) > 0 {
+		result = multierror.Append(result, &feixiaohao.NotWatchedError{Coins: unknown, Source: s.Name()})
+	}
+	return ids, result
+}
+
+// Fetch get price and 24h change of every watched coin in one call.
+// Aliases and @platform selectors are resolved like on the userticker page,
+// the coins that can not be resolved are reported with the records of the
+// others
+func (s *Source) Fetch(filter feixiaohao.CoinFilter) ([]feixiaohao.CoinPriceMeta, error) {
+	metas, err := s.fetch(filter.Symbols())
+	if err != nil && !feixiaohao.Partial(err) {
+		return nil, err
+	}
+	return feixiaohao.SelectRecords(filter, metas), err
+}
+
+// fetch get price and 24h change of coin symbols in one call
+func (s *Source) fetch(coins []string) ([]feixiaohao.CoinPriceMeta, error) {
+	ids, resolveErr := s.Resolve(coins)
+	if resolveErr != nil && (!feixiaohao.Partial(resolveErr) || len(ids) == 0) {
+		return nil, resolveErr
+	}
+
+	idList := make([]string, 0, len(ids))
+	for _, id := range ids {
+		idList = append(idList, id)
+	}
+	sort.Strings(idList)
+
+	var prices map[string]map[string]float64
+	err := s.get("/simple/price", url.Values{
+		"ids":                 {strings.Join(idList, ",")},
+		"vs_currencies":       {s.opts.VsCurrency},
+		"include_24hr_change": {"true"},
+		"include_24hr_vol":    {"true"},
+	}, &prices)
+	if err != nil {
+		return nil, err
+	}
+
+	currency := strings.ToUpper(s.opts.VsCurrency)
+	metas := make([]feixiaohao.CoinPriceMeta, 0, len(ids))
+	for _, coin := range coins {
+		symbol := strings.ToUpper(coin)
+		quote, ok := prices[ids[symbol]]
+		if !ok {
+			continue
+		}
+		last, ok := quote[s.opts.VsCurrency]
+		if !ok {
+			continue
+		}
+		meta := feixiaohao.CoinPriceMeta{
+			Platform: s.opts.Platform,
+			Price:    strconv.FormatFloat(last, 'f', -1, 64) + " " + currency,
+			Percent:  fmt.Sprintf("%.2f%%", quote[s.opts.VsCurrency+"_24h_change"]),
+			CoinType: symbol,
+		}
+		if volume, ok := quote[s.opts.VsCurrency+"_24h_vol"]; ok {
+			meta.Volume = strconv.FormatFloat(volume, 'f', -1, 64) + " " + currency
+		}
+		metas = append(metas, meta)
+	}
+	return metas, resolveErr
+}
@@ -0,0 +1,83 @@
+package coingecko
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+)
+
+// fixtureServer serve the recorded responses in testdata
+func fixtureServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/coins/list":
+			http.ServeFile(w, r, "testdata/coins_list.json")
+		case "/simple/price":
+			query := r.URL.Query()
+			if query.Get("ids") != "bitcoin,cybermiles,iostoken" || query.Get("vs_currencies") != "usd" || query.Get("include_24hr_change") != "true" || query.Get("include_24hr_vol") != "true" {
+				t.Error("bad price query: ", r.URL.RawQuery)
+			}
+			http.ServeFile(w, r, "testdata/simple_price.json")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestFetch(t *testing.T) {
+	server := fixtureServer(t)
+	defer server.Close()
+
+	source := NewSource(CoinGeckoOpt{
+		BaseURL: server.URL,
+		// the id map is matched whatever the case of its symbols
+		IDs: map[string]string{"cmt": "cybermiles"},
+	})
+	metas, err := source.Fetch(feixiaohao.CoinFilter{CoinType: []string{"BTC", "cmt", "IOST"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []feixiaohao.CoinPriceMeta{
+		{Platform: "CoinGecko", Price: "61234.5 USD", Percent: "1.23%", CoinType: "BTC", Volume: "25000000000 USD"},
+		{Platform: "CoinGecko", Price: "0.0123 USD", Percent: "-5.68%", CoinType: "CMT"},
+		{Platform: "CoinGecko", Price: "0.0081 USD", Percent: "3.10%", CoinType: "IOST"},
+	}
+	if len(metas) != len(expect) {
+		t.Fatal("bad metas: ", metas)
+	}
+	for i := range expect {
+		if metas[i] != expect[i] {
+			t.Fatal("expect ", expect[i], " got ", metas[i])
+		}
+	}
+
+	// an unknown symbol does not hide the records of the others
+	metas, err = source.Fetch(feixiaohao.CoinFilter{CoinType: []string{"BTC", "NOPE", "cmt", "IOST"}})
+	if !feixiaohao.NotWatched(err) || !feixiaohao.Partial(err) || !strings.Contains(err.Error(), "NOPE") {
+		t.Fatal("unknown symbol should be reported: ", err)
+	}
+	if len(metas) != len(expect) {
+		t.Fatal("the resolved coins should still be fetched: ", metas)
+	}
+}
+
+func TestResolveAmbiguous(t *testing.T) {
+	server := fixtureServer(t)
+	defer server.Close()
+
+	source := NewSource(CoinGeckoOpt{BaseURL: server.URL})
+	ids, err := source.Resolve([]string{"CMT", "BTC"})
+	if !feixiaohao.Ambiguous(err) || !strings.Contains(err.Error(), "comet, cybermiles") {
+		t.Fatal("ambiguous symbol should list the candidates: ", err)
+	}
+	if len(ids) != 1 || ids["BTC"] != "bitcoin" {
+		t.Fatal("the other symbols should still be resolved: ", ids)
+	}
+	if _, err := source.Resolve([]string{"NOPE"}); !feixiaohao.NotWatched(err) {
+		t.Fatal("unknown symbol should fail: ", err)
+	}
+}
@@ -0,0 +1,170 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StringsSetter is implemented by config types built from a list of
+// strings, like a coin list given as symbols on the command line
+type StringsSetter interface {
+	SetStrings(values []string) error
+}
+
+var (
+	durationType      = reflect.TypeOf(time.Duration(0))
+	timeType          = reflect.TypeOf(time.Time{})
+	stringsSetterType = reflect.TypeOf((*StringsSetter)(nil)).Elem()
+)
+
+// boundField is a struct field reached by walking the options
+type boundField struct {
+	field reflect.StructField
+	value reflect.Value
+	// path is the dotted field path from the options root
+	path string
+	// flagName is the flag name with the names of the parent structs
+	flagName string
+	// split tell if a list take comma separated values, set by the
+	// flagSplit:"true" tag. Values like http headers hold commas
+	split bool
+}
+
+// walkFields call fn on every field of the struct options point to, nested
+// structs are walked too and their flag names prefixed with the flag name
+// of the parent field
+func walkFields(options interface{}, fn func(field boundField) error) error {
+	value := reflect.ValueOf(options)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("options should be a pointer to struct, got %T", options)
+	}
+	return walkStruct(value.Elem(), "", "", fn)
+}
+
+func walkStruct(value reflect.Value, path, prefix string, fn func(field boundField) error) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		bound := boundField{
+			field: field,
+			value: value.Field(i),
+			path:  path + field.Name,
+		}
+		if name := field.Tag.Get("flagName"); name != "" {
+			bound.flagName = prefix + name
+		}
+		bound.split = field.Tag.Get("flagSplit") == "true"
+
+		if isNested(field.Type) {
+			nestedPrefix := prefix
+			if bound.flagName != "" {
+				nestedPrefix = bound.flagName + "-"
+			}
+			if err := walkStruct(bound.value, bound.path+".", nestedPrefix, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(bound); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isNested tell if the type is a struct of config fields
+func isNested(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PtrTo(t).Implements(stringsSetterType)
+}
+
+// isList tell if the field take a list of strings
+func isList(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(stringsSetterType) ||
+		(t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String)
+}
+
+// splitList split comma separated values when split is set, so a list may
+// be given as repeated flags, one comma separated value or a mix of both
+func splitList(values []string, split bool) []string {
+	list := make([]string, 0, len(values))
+	for _, value := range values {
+		items := []string{value}
+		if split {
+			items = strings.Split(value, ",")
+		}
+		for _, item := range items {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// setList store a list of strings into a list field
+func setList(value reflect.Value, values []string, split bool) error {
+	values = splitList(values, split)
+	if setter, ok := value.Addr().Interface().(StringsSetter); ok {
+		return setter.SetStrings(values)
+	}
+	list := reflect.MakeSlice(value.Type(), len(values), len(values))
+	for i, item := range values {
+		list.Index(i).SetString(item)
+	}
+	value.Set(list)
+	return nil
+}
+
+// setText parse text by the kind of value and store it, split tell if a
+// list is comma separated
+func setText(value reflect.Value, text string, split bool) error {
+	if isList(value.Type()) {
+		return setList(value, []string{text}, split)
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q, use true/false", text)
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.Type() == durationType {
+			d, err := time.ParseDuration(text)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q, use a value like 90s or 1h30m", text)
+			}
+			value.SetInt(int64(d))
+			return nil
+		}
+		i, err := strconv.ParseInt(text, 10, 64)
+		if err != nil || value.OverflowInt(i) {
+			return fmt.Errorf("invalid %s %q", value.Type(), text)
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, 64)
+		if err != nil || value.OverflowUint(u) {
+			return fmt.Errorf("invalid %s %q", value.Type(), text)
+		}
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil || value.OverflowFloat(f) {
+			return fmt.Errorf("invalid %s %q", value.Type(), text)
+		}
+		value.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package common
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+type symbolList []string
+
+func (l *symbolList) SetStrings(values []string) error {
+	*l = make(symbolList, 0, len(values))
+	for _, value := range values {
+		*l = append(*l, strings.ToUpper(value))
+	}
+	return nil
+}
+
+type retryOpt struct {
+	Times int           `flagName:"times" default:"2"`
+	Wait  time.Duration `flagName:"wait" default:"5s"`
+}
+
+type testOpt struct {
+	Name    string        `flagName:"name" flagSName:"n" default:"coin"`
+	Enabled bool          `flagName:"enabled" default:"true"`
+	Count   int           `flagName:"count" default:"3"`
+	Small   int8          `flagName:"small" default:"-8"`
+	Period  int64         `flagName:"period" default:"3600"`
+	Size    uint32        `flagName:"size" default:"42"`
+	Low     float32       `flagName:"low" default:"-2.5"`
+	High    float64       `flagName:"high" default:"3.0"`
+	Timeout time.Duration `flagName:"timeout" default:"1m30s"`
+	Tags    []string      `flagName:"tags" flagSplit:"true" default:"a, b"`
+	Coins   symbolList    `flagName:"coins" flagSplit:"true" default:""`
+	Headers []string      `flagName:"headers" default:""`
+	Retry   retryOpt      `flagName:"retry"`
+	Plain   retryOpt
+	Skipped string
+}
+
+func TestApplyDefaultValues(t *testing.T) {
+	options := &testOpt{}
+	if err := ApplyDefaultValues(options); err != nil {
+		t.Fatal(err)
+	}
+
+	want := testOpt{
+		Name:    "coin",
+		Enabled: true,
+		Count:   3,
+		Small:   -8,
+		Period:  3600,
+		Size:    42,
+		Low:     -2.5,
+		High:    3,
+		Timeout: 90 * time.Second,
+		Tags:    []string{"a", "b"},
+		Retry:   retryOpt{Times: 2, Wait: 5 * time.Second},
+		Plain:   retryOpt{Times: 2, Wait: 5 * time.Second},
+	}
+	if !reflect.DeepEqual(*options, want) {
+		t.Fatalf("bad defaults:\n%+v\nwant\n%+v", *options, want)
+	}
+}
+
+func TestApplyDefaultValuesErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		options interface{}
+		message string
+	}{
+		{"bool", &struct {
+			B bool `default:"yes please"`
+		}{}, "default of B: invalid bool"},
+		{"int", &struct {
+			I int `default:"3.5"`
+		}{}, `default of I: invalid int "3.5"`},
+		{"overflow", &struct {
+			I int8 `default:"300"`
+		}{}, `invalid int8 "300"`},
+		{"negative uint", &struct {
+			U uint `default:"-1"`
+		}{}, `invalid uint "-1"`},
+		{"float", &struct {
+			F float32 `default:"high"`
+		}{}, `invalid float32 "high"`},
+		{"duration", &struct {
+			D time.Duration `default:"10"`
+		}{}, "invalid duration"},
+		{"nested", &struct {
+			R retryOpt
+		}{}, ""},
+		{"not a pointer", testOpt{}, "pointer to struct"},
+	}
+
+	for _, c := range cases {
+		err := ApplyDefaultValues(c.options)
+		if c.message == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", c.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.message) {
+			t.Errorf("%s: expect error with %q, got %v", c.name, c.message, err)
+		}
+	}
+}
+
+func TestApplyFlags(t *testing.T) {
+	cases := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		check func(options *testOpt) bool
+	}{
+		{"defaults kept", nil, nil, func(o *testOpt) bool {
+			return o.Name == "coin" && o.High == 3 && len(o.Tags) == 2
+		}},
+		{"short name", []string{"-n", "btc"}, nil, func(o *testOpt) bool {
+			return o.Name == "btc"
+		}},
+		{"numbers", []string{"--low", "-4.5", "--period", "60", "--size", "7", "--small", "9"}, nil, func(o *testOpt) bool {
+			return o.Low == -4.5 && o.Period == 60 && o.Size == 7 && o.Small == 9
+		}},
+		{"duration", []string{"--timeout", "2h"}, nil, func(o *testOpt) bool {
+			return o.Timeout == 2*time.Hour
+		}},
+		{"repeated list", []string{"--tags", "x", "--tags", "y,z"}, nil, func(o *testOpt) bool {
+			return reflect.DeepEqual(o.Tags, []string{"x", "y", "z"})
+		}},
+		{"list setter", []string{"--coins", "btc", "--coins", "eth"}, nil, func(o *testOpt) bool {
+			return reflect.DeepEqual(o.Coins, symbolList{"BTC", "ETH"})
+		}},
+		{"env list", nil, map[string]string{"COIN_COINS": "cmt, iost"}, func(o *testOpt) bool {
+			return reflect.DeepEqual(o.Coins, symbolList{"CMT", "IOST"})
+		}},
+		{"unsplit list", []string{"--headers", "Accept: text/html, application/json"}, nil, func(o *testOpt) bool {
+			return reflect.DeepEqual(o.Headers, []string{"Accept: text/html, application/json"})
+		}},
+		{"env unsplit list", nil, map[string]string{"COIN_HEADERS": "Accept: text/html, application/json\nX-Token: abc"}, func(o *testOpt) bool {
+			return reflect.DeepEqual(o.Headers, []string{"Accept: text/html, application/json", "X-Token: abc"})
+		}},
+		{"env float", nil, map[string]string{"COIN_HIGH": "8.5"}, func(o *testOpt) bool {
+			return o.High == 8.5
+		}},
+		{"nested", []string{"--retry-times", "5", "--retry-wait", "1s", "--times", "9"}, nil, func(o *testOpt) bool {
+			return o.Retry.Times == 5 && o.Retry.Wait == time.Second && o.Plain.Times == 9
+		}},
+	}
+
+	for _, c := range cases {
+		options := &testOpt{}
+		if err := ApplyDefaultValues(options); err != nil {
+			t.Fatal(err)
+		}
+		flags, mappings, err := GenerateFlags(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for key, value := range c.env {
+			os.Setenv(key, value)
+		}
+		app := cli.NewApp()
+		app.Flags = flags
+		app.Action = func(ctx *cli.Context) error {
+			return ApplyFlags(flags, mappings, ctx, options)
+		}
+		err = app.Run(append([]string{"test"}, c.args...))
+		for key := range c.env {
+			os.Unsetenv(key)
+		}
+
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if !c.check(options) {
+			t.Errorf("%s: bad options %+v", c.name, *options)
+		}
+	}
+}
+
+func TestApplyFlagsErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{"bad float", []string{"--low", "abc"}, nil},
+		{"bad duration", []string{"--timeout", "10"}, nil},
+		{"bad env int", nil, map[string]string{"COIN_PERIOD": "soon"}},
+		{"overflow", []string{"--small", "1000"}, nil},
+	}
+
+	for _, c := range cases {
+		options := &testOpt{}
+		flags, mappings, err := GenerateFlags(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for key, value := range c.env {
+			os.Setenv(key, value)
+		}
+		app := cli.NewApp()
+		app.Flags = flags
+		app.Writer = devNull{}
+		app.ErrWriter = devNull{}
+		app.Action = func(ctx *cli.Context) error {
+			return ApplyFlags(flags, mappings, ctx, options)
+		}
+		err = app.Run(append([]string{"test"}, c.args...))
+		for key := range c.env {
+			os.Unsetenv(key)
+		}
+
+		if err == nil {
+			t.Errorf("%s: expect error", c.name)
+		}
+	}
+}
+
+func TestGenerateFlagsUnsupported(t *testing.T) {
+	options := &struct {
+		Levels map[string]string `flagName:"levels"`
+	}{}
+	if _, _, err := GenerateFlags(options); err == nil || !strings.Contains(err.Error(), "Levels") {
+		t.Fatal("map flag should fail with the field name: ", err)
+	}
+}
+
+type devNull struct{}
+
+func (devNull) Write(p []byte) (int, error) {
+	return len(p), nil
+}
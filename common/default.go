@@ -2,40 +2,19 @@ package common
 
 import (
 	"fmt"
-	"reflect"
-	"strconv"
-	"github.com/fatih/structs"
 )
 
+// ApplyDefaultValues set every field with a default tag to the parsed tag
+// value, nested structs included
 func ApplyDefaultValues(struct_ interface{}) (err error) {
-	o := structs.New(struct_)
-
-	for _, field := range o.Fields() {
-		defaultValue := field.Tag("default")
+	return walkFields(struct_, func(field boundField) error {
+		defaultValue := field.field.Tag.Get("default")
 		if defaultValue == "" {
-			continue
+			return nil
 		}
-		var val interface{}
-		switch field.Kind() {
-		case reflect.String:
-			val = defaultValue
-		case reflect.Bool:
-			if defaultValue == "true" {
-				val = true
-			} else if defaultValue == "false" {
-				val = false
-			} else {
-				return fmt.Errorf("invalid bool expression: %v, use true/false", defaultValue)
-			}
-		case reflect.Int:
-			val, err = strconv.Atoi(defaultValue)
-			if err != nil {
-				return err
-			}
-		default:
-			val = field.Value()
+		if err := setText(field.value, defaultValue, field.split); err != nil {
+			return fmt.Errorf("default of %s: %s", field.path, err)
 		}
-		field.Set(val)
-	}
-	return nil
-}
\ No newline at end of file
+		return nil
+	})
+}
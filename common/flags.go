@@ -1,169 +1,285 @@
 package common
 
 import (
-	"log"
+	"fmt"
 	"os"
+	"time"
 	"reflect"
+	"regexp"
 	"strings"
 	"io/ioutil"
 	"gopkg.in/yaml.v2"
-	"github.com/yudai/hcl"
 	"github.com/urfave/cli"
-	"github.com/fatih/structs"
 	"github.com/yudai/gotty/pkg/homedir"
+	"github.com/smileboywtu/CoinNotify/store"
 )
 
+// GenerateFlags build a cli flag for every field with a flagName tag, the
+// env var is the flag name upper cased with a COIN_ prefix. mappings map
+// the flag names to the field paths for ApplyFlags
 func GenerateFlags(options ...interface{}) (flags []cli.Flag, mappings map[string]string, err error) {
 	mappings = make(map[string]string)
 
 	for _, struct_ := range options {
-		o := structs.New(struct_)
-		for _, field := range o.Fields() {
-			flagName := field.Tag("flagName")
+		err = walkFields(struct_, func(field boundField) error {
+			flagName := field.flagName
 			if flagName == "" {
-				continue
+				return nil
 			}
-			envName := "COIN_" + strings.ToUpper(strings.Join(strings.Split(flagName, "-"), "_"))
-			mappings[flagName] = field.Name()
+			envName := EnvName(flagName)
+			mappings[flagName] = field.path
 
-			flagShortName := field.Tag("flagSName")
+			flagShortName := field.field.Tag.Get("flagSName")
 			if flagShortName != "" {
 				flagName += ", " + flagShortName
 			}
 
-			flagDescription := field.Tag("flagDescribe")
-
-			switch field.Kind() {
-			case reflect.String:
-				flags = append(flags, cli.StringFlag{
-					Name:   flagName,
-					Value:  field.Value().(string),
-					Usage:  flagDescription,
-					EnvVar: envName,
-				})
-			case reflect.Bool:
-				flags = append(flags, cli.BoolFlag{
-					Name:   flagName,
-					Usage:  flagDescription,
-					EnvVar: envName,
-				})
-			case reflect.Int:
-				flags = append(flags, cli.IntFlag{
-					Name:   flagName,
-					Value:  field.Value().(int),
-					Usage:  flagDescription,
-					EnvVar: envName,
-				})
+			flagDescription := field.field.Tag.Get("flagDescribe")
+
+			flag, err := newFlag(field, flagName, flagDescription, envName)
+			if err != nil {
+				return err
 			}
+			flags = append(flags, flag)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
 		}
 	}
 
 	return
 }
 
+// newFlag create the cli flag of the field kind, the current field value
+// is the flag default
+func newFlag(field boundField, name, usage, envName string) (cli.Flag, error) {
+	value := field.value
+	if isList(value.Type()) {
+		// the slice flag append to its default, the default is in the
+		// field already
+		if defaultValue := field.field.Tag.Get("default"); defaultValue != "" {
+			usage += fmt.Sprintf(" (default: %s)", defaultValue)
+		}
+		// cli split env lists on commas, the unsplit ones read one value a
+		// line in ApplyFlags
+		if !field.split {
+			envName = ""
+		}
+		return cli.StringSliceFlag{Name: name, Usage: usage, EnvVar: envName}, nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return cli.StringFlag{Name: name, Value: value.String(), Usage: usage, EnvVar: envName}, nil
+	case reflect.Bool:
+		return cli.BoolFlag{Name: name, Usage: usage, EnvVar: envName}, nil
+	case reflect.Int:
+		return cli.IntFlag{Name: name, Value: int(value.Int()), Usage: usage, EnvVar: envName}, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.Type() == durationType {
+			return cli.DurationFlag{Name: name, Value: time.Duration(value.Int()), Usage: usage, EnvVar: envName}, nil
+		}
+		return cli.Int64Flag{Name: name, Value: value.Int(), Usage: usage, EnvVar: envName}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cli.Uint64Flag{Name: name, Value: value.Uint(), Usage: usage, EnvVar: envName}, nil
+	case reflect.Float32, reflect.Float64:
+		return cli.Float64Flag{Name: name, Value: value.Float(), Usage: usage, EnvVar: envName}, nil
+	}
+	return nil, fmt.Errorf("field %s: type %s can not be a flag", field.path, value.Type())
+}
+
+// ApplyFlags copy the flags set on the command line or by env var into the
+// fields mapped by GenerateFlags
 func ApplyFlags(
 	flags []cli.Flag,
 	mappingHint map[string]string,
 	c *cli.Context,
 	options ...interface{},
-) {
-	objects := make([]*structs.Struct, len(options))
-	for i, struct_ := range options {
-		objects[i] = structs.New(struct_)
+) error {
+	for _, struct_ := range options {
+		err := walkFields(struct_, func(field boundField) error {
+			flagName := field.flagName
+			if flagName == "" || mappingHint[flagName] != field.path {
+				return nil
+			}
+			if !c.IsSet(flagName) {
+				if env, ok := os.LookupEnv(EnvName(flagName)); ok && isList(field.value.Type()) && !field.split {
+					return setList(field.value, strings.Split(env, "\n"), false)
+				}
+				return nil
+			}
+			if err := applyFlag(field.value, flagName, field.split, c); err != nil {
+				return fmt.Errorf("flag --%s: %s", flagName, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	for flagName, fieldName := range mappingHint {
-		if !c.IsSet(flagName) {
-			continue
+func applyFlag(value reflect.Value, flagName string, split bool, c *cli.Context) error {
+	if isList(value.Type()) {
+		return setList(value, c.StringSlice(flagName), split)
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(c.String(flagName))
+	case reflect.Bool:
+		value.SetBool(c.Bool(flagName))
+	case reflect.Int:
+		value.SetInt(int64(c.Int(flagName)))
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := c.Int64(flagName)
+		if value.Type() == durationType {
+			i = int64(c.Duration(flagName))
 		}
-		var field *structs.Field
-		var ok bool
-		for _, o := range objects {
-			field, ok = o.FieldOk(fieldName)
-			if ok {
-				break
-			}
+		if value.OverflowInt(i) {
+			return fmt.Errorf("%d overflow %s", i, value.Type())
 		}
-		if field == nil {
-			continue
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := c.Uint64(flagName)
+		if value.OverflowUint(u) {
+			return fmt.Errorf("%d overflow %s", u, value.Type())
 		}
-		var val interface{}
-		switch field.Kind() {
-		case reflect.String:
-			val = c.String(flagName)
-		case reflect.Bool:
-			val = c.Bool(flagName)
-		case reflect.Int:
-			val = c.Int(flagName)
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f := c.Float64(flagName)
+		if value.OverflowFloat(f) {
+			return fmt.Errorf("%g overflow %s", f, value.Type())
 		}
-		field.Set(val)
+		value.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
 	}
+	return nil
 }
 
-func ApplyConfigFile(filePath string, options ...interface{}) error {
-	filePath = homedir.Expand(filePath)
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return err
-	}
-
-	fileString := []byte{}
-	log.Printf("Loading config file at: %s", filePath)
-	fileString, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		return err
+// SaveConfigFileYaml write options to a yaml config file, the file is
+// created when missing and only readable by the user. The keys of options
+// replace the same keys of an existing file in place, the comments and the
+// other keys are kept and new keys are appended
+func SaveConfigFileYaml(filePath string, options ...interface{}) error {
+	if filePath == "" {
+		return fmt.Errorf("empty config file path")
 	}
+	filePath = homedir.Expand(filePath)
 
+	var values yaml.MapSlice
 	for _, object := range options {
-		if err := hcl.Decode(object, string(fileString)); err != nil {
+		byteString, err := yaml.Marshal(object)
+		if err != nil {
 			return err
 		}
+		var items yaml.MapSlice
+		if err := yaml.Unmarshal(byteString, &items); err != nil {
+			return err
+		}
+		values = mergeMapSlice(values, items)
 	}
 
-	return nil
-}
-
-func ApplyConfigFileYaml(filePath string, options ...interface{}) error {
-	filePath = homedir.Expand(filePath)
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
-
-	fileString := []byte{}
-	log.Printf("Loading config file at: %s", filePath)
-	fileString, err := ioutil.ReadFile(filePath)
+	merged, err := mergeYamlLines(string(content), values)
 	if err != nil {
 		return err
 	}
+	return store.WriteFile(filePath, []byte(merged))
+}
 
-	for _, object := range options {
-		if err := yaml.Unmarshal(fileString, object); err != nil {
-			return err
+// mergeMapSlice set the items on values, a later item override the same key
+func mergeMapSlice(values, items yaml.MapSlice) yaml.MapSlice {
+	for _, item := range items {
+		found := false
+		for i := range values {
+			if values[i].Key == item.Key {
+				values[i].Value = item.Value
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, item)
 		}
-
 	}
-
-	return nil
+	return values
 }
 
-func SaveConfigFileYaml(filePath string, options ...interface{}) error {
-	filePath = homedir.Expand(filePath)
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return err
+// topLevelKey match a key at the start of a line, the value may follow
+var topLevelKey = regexp.MustCompile(`^([^\s#'"\-][^:#]*|"[^"]*"|'[^']*'):(\s|$)`)
+
+// mergeYamlLines replace the blocks of the keys in content with values,
+// a block is the key line with the indented or list lines under it
+func mergeYamlLines(content string, values yaml.MapSlice) (string, error) {
+	rendered := make(map[string]string, len(values))
+	for _, item := range values {
+		byteString, err := yaml.Marshal(yaml.MapSlice{item})
+		if err != nil {
+			return "", err
+		}
+		rendered[fmt.Sprint(item.Key)] = strings.TrimRight(string(byteString), "\n")
 	}
 
-	fd, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
-		return err
+	var lines []string
+	if content = strings.TrimSuffix(content, "\n"); content != "" {
+		lines = strings.Split(content, "\n")
 	}
-	defer fd.Close()
 
-	for _, object := range options {
-		if byteString, err := yaml.Marshal(object); err != nil {
-			return err
-		} else {
-			fd.Write(byteString)
+	var merged []string
+	written := make(map[string]bool)
+	for i := 0; i < len(lines); {
+		match := topLevelKey.FindStringSubmatch(lines[i])
+		if match == nil {
+			merged = append(merged, lines[i])
+			i++
+			continue
 		}
+		key := strings.Trim(strings.TrimSpace(match[1]), `"'`)
+		block, ok := rendered[key]
+		if !ok {
+			merged = append(merged, lines[i])
+			i++
+			continue
+		}
+		if !written[key] {
+			merged = append(merged, block)
+			written[key] = true
+		}
+		i = blockEnd(lines, i)
 	}
 
-	return nil
+	for _, item := range values {
+		if key := fmt.Sprint(item.Key); !written[key] {
+			merged = append(merged, rendered[key])
+		}
+	}
+	return strings.Join(merged, "\n") + "\n", nil
+}
+
+// blockEnd return the line after the block of the key at start, blank
+// lines belong to the block only when it goes on after them
+func blockEnd(lines []string, start int) int {
+	inBlock := func(line string) bool {
+		return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "-")
+	}
+
+	end := start + 1
+	for next := end; next < len(lines); next++ {
+		line := lines[next]
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !inBlock(line) {
+			break
+		}
+		end = next + 1
+	}
+	return end
 }
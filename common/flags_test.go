@@ -0,0 +1,90 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type savedOpt struct {
+	UserName string   `yaml:"userid"`
+	Period   int64    `yaml:"notifytimeperiod"`
+	Coins    []string `yaml:"cointype"`
+	Phone    string   `yaml:"notifyphone"`
+}
+
+func TestSaveConfigFileYaml(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coinnotify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a new file is created with only user permissions
+	path := filepath.Join(dir, "conf", "config.yaml")
+	if err := SaveConfigFileYaml(path, savedOpt{UserName: "bob", Period: 3600}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("bad permissions %v", info.Mode().Perm())
+	}
+
+	// an existing file keep its comments and order, the old values are gone
+	existing := `# feixiaohao
+userid: alice
+passwd: secret
+
+# coins
+cointype:
+ - CMT
+ - IOST
+ - EOS
+# - BTC
+
+# seconds
+notifytimeperiod:
+`
+	if err := ioutil.WriteFile(path, []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+	saved := savedOpt{UserName: "bob", Period: 600, Coins: []string{"BTC"}, Phone: "13800000000"}
+	if err := SaveConfigFileYaml(path, saved); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# feixiaohao
+userid: bob
+passwd: secret
+
+# coins
+cointype:
+- BTC
+# - BTC
+
+# seconds
+notifytimeperiod: 600
+notifyphone: "13800000000"
+`
+	if string(content) != want {
+		t.Fatalf("bad merge:\n%s\nwant\n%s", content, want)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Fatalf("bad permissions %v", info.Mode().Perm())
+	}
+
+	var loaded savedOpt
+	if err := ApplyConfigFile(path, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.UserName != "bob" || loaded.Period != 600 || len(loaded.Coins) != 1 {
+		t.Fatalf("bad reload %+v", loaded)
+	}
+}
@@ -0,0 +1,413 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/yudai/gotty/pkg/homedir"
+	"github.com/yudai/hcl"
+	"gopkg.in/yaml.v2"
+)
+
+// Config file formats
+const (
+	FormatYAML = "yaml"
+	FormatHCL  = "hcl"
+	FormatTOML = "toml"
+	FormatJSON = "json"
+)
+
+// IncludeKey list the config files read before the file that set it, the
+// file override them
+const IncludeKey = "include"
+
+// DropInDir is the directory beside a config file whose files are read
+// after it and override it, in name order
+const DropInDir = "conf.d"
+
+// formatExtensions map the file extensions to the formats
+var formatExtensions = map[string]string{
+	".yaml": FormatYAML,
+	".yml":  FormatYAML,
+	".hcl":  FormatHCL,
+	".toml": FormatTOML,
+	".tml":  FormatTOML,
+	".json": FormatJSON,
+}
+
+var (
+	hclBlock   = regexp.MustCompile(`(?m)^\s*("[^"]*"|[\w\-]+)(\s+"[^"]*")*\s*\{\s*$`)
+	tomlTable  = regexp.MustCompile(`(?m)^\s*\[\[?[^\[\]]+\]\]?\s*(#.*)?$`)
+	assignment = regexp.MustCompile(`(?m)^\s*("[^"]*"|[\w.\-]+)\s*=`)
+)
+
+// ConfigFormat tell the format of a config file by its extension, or by
+// its content when the extension is unknown. key = value files without
+// blocks are read as toml, anything else as yaml
+func ConfigFormat(filePath string, content []byte) string {
+	if format, ok := formatExtensions[strings.ToLower(filepath.Ext(filePath))]; ok {
+		return format
+	}
+
+	text := strings.TrimSpace(string(content))
+	switch {
+	case strings.HasPrefix(text, "{"):
+		return FormatJSON
+	case hclBlock.MatchString(text):
+		return FormatHCL
+	case tomlTable.MatchString(text) || assignment.MatchString(text):
+		return FormatTOML
+	}
+	return FormatYAML
+}
+
+// ApplyConfigFile read a config file of any format with its includes and
+// drop-ins into options, the keys are the yaml names of the fields. Each
+// file is applied on top of the ones before it
+func ApplyConfigFile(filePath string, options ...interface{}) error {
+	files, err := ConfigFiles(filePath)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		log.Printf("Loading config file at: %s", file)
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := applyConfig(ConfigFormat(file, content), content, options...); err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+	}
+	return nil
+}
+
+// applyConfig decode content into options, yaml directly and the other
+// formats through yaml so the field names are the same
+func applyConfig(format string, content []byte, options ...interface{}) error {
+	if format == FormatYAML {
+		for _, object := range options {
+			if err := yaml.Unmarshal(content, object); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	values, err := decodeConfig(format, content)
+	if err != nil {
+		return err
+	}
+	delete(values, IncludeKey)
+	for _, object := range options {
+		byteString, err := yaml.Marshal(fitValue(values, reflect.TypeOf(object)))
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(byteString, object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadConfigFile decode a config file with its includes and drop-ins into
+// one map, maps are merged key by key
+func ReadConfigFile(filePath string) (map[string]interface{}, error) {
+	files, err := ConfigFiles(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		override, err := decodeConfig(ConfigFormat(file, content), content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		delete(override, IncludeKey)
+		mergeValues(values, override)
+	}
+	return values, nil
+}
+
+// ConfigFiles list the files a config file is read from, a later file
+// override the earlier ones. The files of the include key come before the
+// file that set it, the files in the conf.d directory beside it come last
+func ConfigFiles(filePath string) ([]string, error) {
+	if filePath == "" {
+		return nil, fmt.Errorf("empty config file path")
+	}
+	filePath = expandPath(filePath)
+
+	files, err := includeTree(filePath, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+
+	dropins, err := configDir(filepath.Join(filepath.Dir(filePath), DropInDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, dropin := range dropins {
+		tree, err := includeTree(dropin, make(map[string]bool))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, tree...)
+	}
+	return files, nil
+}
+
+// includeTree list the includes of one file before it, reading hold the
+// files on the include chain to catch loops
+func includeTree(filePath string, reading map[string]bool) ([]string, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if reading[absPath] {
+		return nil, fmt.Errorf("config include loop at %s", filePath)
+	}
+	reading[absPath] = true
+	defer delete(reading, absPath)
+
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	values, err := decodeConfig(ConfigFormat(filePath, content), content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filePath, err)
+	}
+	includes, err := includePaths(values[IncludeKey], filepath.Dir(filePath))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filePath, err)
+	}
+
+	var files []string
+	for _, include := range includes {
+		tree, err := includeTree(include, reading)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, tree...)
+	}
+	return append(files, filePath), nil
+}
+
+// decodeConfig decode content into a map with string keys
+func decodeConfig(format string, content []byte) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	var err error
+	switch format {
+	case FormatYAML:
+		err = yaml.Unmarshal(content, &values)
+	case FormatJSON:
+		err = json.Unmarshal(content, &values)
+	case FormatHCL:
+		err = hcl.Decode(&values, string(content))
+	case FormatTOML:
+		_, err = toml.Decode(string(content), &values)
+	default:
+		err = fmt.Errorf("unknown config format %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	normalized, _ := normalizeValue(values).(map[string]interface{})
+	if normalized == nil {
+		normalized = make(map[string]interface{})
+	}
+	return normalized, nil
+}
+
+// normalizeValue turn the yaml maps into string keyed maps and the hcl
+// blocks into maps, a block repeated is a list of maps
+func normalizeValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		values := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			values[fmt.Sprint(key)] = normalizeValue(item)
+		}
+		return values
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = normalizeValue(item)
+		}
+		return value
+	case []map[string]interface{}:
+		if len(value) == 1 {
+			return normalizeValue(value[0])
+		}
+		values := make([]interface{}, len(value))
+		for i, item := range value {
+			values[i] = normalizeValue(item)
+		}
+		return values
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalizeValue(item)
+		}
+		return value
+	}
+	return value
+}
+
+// fitValue shape value after the type it is read into, a single map given
+// for a list become a list of one
+func fitValue(value interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		if item, ok := value.(map[string]interface{}); ok {
+			value = []interface{}{item}
+		}
+		if list, ok := value.([]interface{}); ok {
+			for i, item := range list {
+				list[i] = fitValue(item, t.Elem())
+			}
+		}
+	case reflect.Map:
+		if values, ok := value.(map[string]interface{}); ok {
+			for key, item := range values {
+				values[key] = fitValue(item, t.Elem())
+			}
+		}
+	case reflect.Struct:
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			if item, ok := values[name]; ok {
+				values[name] = fitValue(item, field.Type)
+			}
+		}
+	}
+	return value
+}
+
+// mergeValues set src on dst, maps are merged key by key and anything else
+// is replaced
+func mergeValues(dst, src map[string]interface{}) {
+	for key, value := range src {
+		dstMap, dstOK := dst[key].(map[string]interface{})
+		srcMap, srcOK := value.(map[string]interface{})
+		if dstOK && srcOK {
+			mergeValues(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// includePaths resolve the include value, a path or a list of them,
+// relative to dir. A glob match files and a directory include its config
+// files in name order
+func includePaths(value interface{}, dir string) ([]string, error) {
+	var patterns []string
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		patterns = []string{value}
+	case []interface{}:
+		for _, item := range value {
+			pattern, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s should list paths, got %v", IncludeKey, item)
+			}
+			patterns = append(patterns, pattern)
+		}
+	default:
+		return nil, fmt.Errorf("%s should be a path or a list of paths, got %v", IncludeKey, value)
+	}
+
+	var paths []string
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		pattern = expandPath(pattern)
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+
+		if strings.ContainsAny(pattern, "*?[") {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, err
+			}
+			sort.Strings(matches)
+			paths = append(paths, matches...)
+			continue
+		}
+
+		if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			files, err := configDir(pattern)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, files...)
+			continue
+		}
+		paths = append(paths, pattern)
+	}
+	return paths, nil
+}
+
+// configDir list the config files of dir in name order, a missing dir
+// has none
+func configDir(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if _, ok := formatExtensions[strings.ToLower(filepath.Ext(entry.Name()))]; ok {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// expandPath expand a leading ~/, homedir.Expand fail on short paths
+func expandPath(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		return homedir.Expand(path)
+	}
+	return path
+}
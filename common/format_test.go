@@ -0,0 +1,196 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type robotOpt struct {
+	Name    string `yaml:"name"`
+	Webhook string `yaml:"webhook"`
+}
+
+type formatOpt struct {
+	UserName string            `yaml:"userid"`
+	Phone    string            `yaml:"notifyphone"`
+	Period   int64             `yaml:"notifytimeperiod"`
+	Low      float32           `yaml:"lowpricepercent"`
+	Enabled  bool              `yaml:"failovernotify"`
+	Coins    []string          `yaml:"cointype"`
+	Aliases  map[string]string `yaml:"aliases"`
+	Robots   []robotOpt        `yaml:"dingtalk"`
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConfigFormat(t *testing.T) {
+	cases := []struct {
+		path    string
+		content string
+		format  string
+	}{
+		{"config.yml", "", FormatYAML},
+		{"config.HCL", "", FormatHCL},
+		{"config.toml", "", FormatTOML},
+		{"config.json", "", FormatJSON},
+		{"config", `{"userid": "bob"}`, FormatJSON},
+		{"config", "userid = \"bob\"\naliases {\n  a = \"b\"\n}\n", FormatHCL},
+		{"config", "userid = \"bob\"\n[aliases]\na = \"b\"\n", FormatTOML},
+		{"config", "userid = \"bob\"\n", FormatTOML},
+		{"config", "userid: bob\nwebhookurl: http://host/?a=b\n", FormatYAML},
+	}
+	for _, c := range cases {
+		if format := ConfigFormat(c.path, []byte(c.content)); format != c.format {
+			t.Errorf("%s %q: got %s, want %s", c.path, c.content, format, c.format)
+		}
+	}
+}
+
+func TestApplyConfigFileFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coinnotify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	want := formatOpt{
+		UserName: "bob",
+		Phone:    "13800000000",
+		Period:   600,
+		Low:      -2.5,
+		Enabled:  true,
+		Coins:    []string{"BTC", "ETH"},
+		Aliases:  map[string]string{"大饼": "BTC"},
+		Robots:   []robotOpt{{Name: "ops", Webhook: "https://robot"}},
+	}
+	files := map[string]string{
+		"config.yaml": `
+userid: bob
+notifyphone: "13800000000"
+notifytimeperiod: 600
+lowpricepercent: -2.5
+failovernotify: true
+cointype: [BTC, ETH]
+aliases: {大饼: BTC}
+dingtalk:
+  - name: ops
+    webhook: https://robot
+`,
+		"config.json": `{
+  "userid": "bob", "notifyphone": "13800000000", "notifytimeperiod": 600,
+  "lowpricepercent": -2.5, "failovernotify": true, "cointype": ["BTC", "ETH"],
+  "aliases": {"大饼": "BTC"}, "dingtalk": [{"name": "ops", "webhook": "https://robot"}]
+}`,
+		"config.hcl": `
+userid = "bob"
+notifyphone = "13800000000"
+notifytimeperiod = 600
+lowpricepercent = -2.5
+failovernotify = true
+cointype = ["BTC", "ETH"]
+aliases {
+  "大饼" = "BTC"
+}
+dingtalk {
+  name = "ops"
+  webhook = "https://robot"
+}
+`,
+		"config.toml": `
+# account
+userid = "bob"
+notifyphone = '13800000000'
+notifytimeperiod = 6_00
+lowpricepercent = -2.5 # percent
+failovernotify = true
+cointype = [
+  "BTC",
+  "ETH", # trailing comma
+]
+
+[aliases]
+"大饼" = "BTC"
+
+[[dingtalk]]
+name = "ops"
+webhook = "https://robot"
+`,
+	}
+	for name := range files {
+		sub := filepath.Join(dir, strings.TrimPrefix(filepath.Ext(name), "."))
+		writeFiles(t, sub, map[string]string{name: files[name]})
+
+		var options formatOpt
+		if err := ApplyConfigFile(filepath.Join(sub, name), &options); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if !reflect.DeepEqual(options, want) {
+			t.Errorf("%s: bad options\n%+v\nwant\n%+v", name, options, want)
+		}
+	}
+}
+
+func TestApplyConfigFileInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coinnotify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"shared/base.yaml":     "userid: shared\nnotifytimeperiod: 3600\ncointype: [BTC]\naliases: {大饼: BTC}\n",
+		"shared/extra.toml":    "lowpricepercent = -3.0\n",
+		"host.yaml":            "include: [shared/base.yaml, shared/*.toml]\nuserid: host\naliases: {姨太: ETH}\n",
+		"conf.d/10-coins.json": `{"cointype": ["ETH"]}`,
+		"conf.d/20-phone.hcl":  "notifyphone = \"13800000000\"\n",
+		"conf.d/notes.txt":     "not a config",
+	})
+
+	var options formatOpt
+	if err := ApplyConfigFile(filepath.Join(dir, "host.yaml"), &options); err != nil {
+		t.Fatal(err)
+	}
+	want := formatOpt{
+		UserName: "host",
+		Phone:    "13800000000",
+		Period:   3600,
+		Low:      -3,
+		Coins:    []string{"ETH"},
+		Aliases:  map[string]string{"大饼": "BTC", "姨太": "ETH"},
+	}
+	if !reflect.DeepEqual(options, want) {
+		t.Fatalf("bad merge\n%+v\nwant\n%+v", options, want)
+	}
+
+	keys, err := FileKeys(filepath.Join(dir, "host.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"userid", "lowpricepercent", "notifyphone", "cointype"} {
+		if !keys[key] {
+			t.Errorf("key %s should come from the files", key)
+		}
+	}
+	if keys[IncludeKey] {
+		t.Error("include is not a config key")
+	}
+
+	writeFiles(t, dir, map[string]string{"shared/extra.toml": "include = \"../host.yaml\"\n"})
+	if err := ApplyConfigFile(filepath.Join(dir, "host.yaml"), &options); err == nil || !strings.Contains(err.Error(), "loop") {
+		t.Fatal("include loop should fail: ", err)
+	}
+}
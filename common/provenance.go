@@ -0,0 +1,76 @@
+package common
+
+import (
+	"flag"
+	"io/ioutil"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// Sources of a config value, a later source override the earlier ones
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// EnvName return the env var bound to a flag
+func EnvName(flagName string) string {
+	return "COIN_" + strings.ToUpper(strings.Join(strings.Split(flagName, "-"), "_"))
+}
+
+// FileKeys return the top level keys set by a config file, its includes
+// and drop-ins
+func FileKeys(filePath string) (map[string]bool, error) {
+	values, err := ReadConfigFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool, len(values))
+	for key := range values {
+		keys[key] = true
+	}
+	return keys, nil
+}
+
+// Provenance tell the source of every field of options by its path, flags
+// must come from GenerateFlags on the same options and args is the command
+// line they were parsed from, without the program name. Only the flags
+// given in args are sources, the cli also count the env vars as set
+func Provenance(options interface{}, flags []cli.Flag, args []string, envLookup func(string) (string, bool), fileKeys map[string]bool) (map[string]string, error) {
+	set := flag.NewFlagSet("provenance", flag.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	for _, f := range flags {
+		f.Apply(set)
+	}
+	// the cli already parsed args, an error only stop at the unknown flag
+	set.Parse(args)
+	parsed := make(map[string]bool)
+	set.Visit(func(f *flag.Flag) {
+		parsed[f.Name] = true
+	})
+
+	sources := make(map[string]string)
+	err := walkFields(options, func(field boundField) error {
+		source := SourceDefault
+
+		yamlName := strings.Split(field.field.Tag.Get("yaml"), ",")[0]
+		if yamlName != "" && !strings.Contains(field.path, ".") && fileKeys[yamlName] {
+			source = SourceFile
+		}
+
+		if field.flagName != "" {
+			short := field.field.Tag.Get("flagSName")
+			if parsed[field.flagName] || (short != "" && parsed[short]) {
+				source = SourceFlag
+			} else if _, ok := envLookup(EnvName(field.flagName)); ok {
+				source = SourceEnv
+			}
+		}
+		sources[field.path] = source
+		return nil
+	})
+	return sources, err
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+type provenanceOpt struct {
+	Name  string   `yaml:"name" flagName:"name" flagSName:"n" default:"coin"`
+	Count int      `yaml:"count" flagName:"count" default:"3"`
+	Tags  []string `yaml:"tags" flagName:"tags" default:""`
+	Key   string   `yaml:"key" flagName:"key" default:""`
+	Rules []string `yaml:"rules"`
+	Retry retryOpt `yaml:"retry" flagName:"retry"`
+}
+
+func TestProvenance(t *testing.T) {
+	env := map[string]string{"COIN_COUNT": "5", "COIN_KEY": "k", "COIN_RETRY_TIMES": "1"}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	// a negative value is not a flag name and a flag win over its env var
+	args := []string{"test", "-n", "-2.5", "--count", "7", "run", "--", "--tags"}
+	fileKeys := map[string]bool{"count": true, "tags": true, "rules": true, "retry": true}
+
+	flags, _, err := GenerateFlags(&provenanceOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sources map[string]string
+	app := cli.NewApp()
+	app.Flags = flags
+	app.Action = func(c *cli.Context) error {
+		sources, err = Provenance(&provenanceOpt{}, flags, args[1:], lookup, fileKeys)
+		return err
+	}
+	if err := app.Run(args); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"Name":        SourceFlag,
+		"Count":       SourceFlag,
+		"Tags":        SourceFile,
+		"Key":         SourceEnv,
+		"Rules":       SourceFile,
+		"Retry.Times": SourceEnv,
+		"Retry.Wait":  SourceDefault,
+	}
+	if !reflect.DeepEqual(sources, want) {
+		t.Fatalf("bad sources:\n%v\nwant\n%v", sources, want)
+	}
+}
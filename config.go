@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/structs"
+	"github.com/smileboywtu/CoinNotify/common"
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+)
+
+// phonePattern match one sms phone number, a mainland number or one with
+// the country code
+var phonePattern = regexp.MustCompile(`^(\+|00)?[0-9]{6,15}$`)
+
+// redacted replace the secrets in config show
+const redacted = "******"
+
+// configCommand check or print the merged config
+func configCommand(load func(c *cli.Context) *AppConfigOpt, provenance func(c *cli.Context) (map[string]string, error)) cli.Command {
+	return cli.Command{
+		Name:  "config",
+		Usage: "Validate or show the merged config",
+		Subcommands: []cli.Command{
+			{
+				Name:  "validate",
+				Usage: "Check credentials, thresholds, phone and coins",
+				Action: func(c *cli.Context) {
+					problems, notes := ValidateConfig(load(c))
+					for _, note := range notes {
+						fmt.Println("note: " + note)
+					}
+					for _, problem := range problems {
+						fmt.Println("error: " + problem)
+					}
+					if len(problems) > 0 {
+						exit(fmt.Errorf("config has %d problems", len(problems)), 1)
+					}
+					fmt.Println("config ok")
+				},
+			},
+			{
+				Name:  "show",
+				Usage: "Print the effective config with the source of each value",
+				Action: func(c *cli.Context) {
+					config := load(c)
+					sources, err := provenance(c)
+					if err != nil {
+						exit(err, 2)
+					}
+					if err := ShowConfig(os.Stdout, config, sources); err != nil {
+						exit(err, 1)
+					}
+				},
+			},
+		},
+	}
+}
+
+// ValidateConfig check config before it run, problems stop the run and
+// notes are for information
+func ValidateConfig(config *AppConfigOpt) (problems []string, notes []string) {
+	// require take name and value pairs
+	require := func(reason string, pairs ...string) {
+		for i := 0; i+1 < len(pairs); i += 2 {
+			if strings.TrimSpace(pairs[i+1]) == "" {
+				problems = append(problems, fmt.Sprintf("%s need %s", reason, pairs[i]))
+			}
+		}
+	}
+
+	// credentials of the sources
+	if usesSource(config, "feixiaohao") {
+		require("feixiaohao source", "userid", config.UserName, "passwd", config.PassWD)
+	}
+
+	// credentials of the notifiers
+	names := config.Notifiers
+	if len(names) == 0 {
+		names = defaultNotifiers
+	}
+	for _, name := range names {
+		switch name {
+		case "aliyun":
+			require("aliyun sms",
+				"accesskey", config.AccessKey,
+				"accessid", config.AccessID,
+				"signname", config.SignName,
+				"templatecode", config.TemplateCode,
+				"notifyphone", config.NotifyPhone)
+		case "webhook":
+			require("webhook", "webhookurl", config.WebhookURL)
+		case "email":
+			require("email", "smtphost", config.SMTPHost, "mailto", strings.Join(config.MailTo, ","))
+		case "telegram":
+			require("telegram", "telegramtoken", config.TelegramToken, "telegramchatids", strings.Join(config.TelegramChatIDs, ","))
+		}
+	}
+	if _, err := NewReload(config); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	// phone numbers, aliyun take a comma separated list
+	if config.NotifyPhone != "" {
+		for _, phone := range strings.Split(config.NotifyPhone, ",") {
+			if !phonePattern.MatchString(strings.TrimSpace(phone)) {
+				problems = append(problems, fmt.Sprintf("notifyphone %q is not a phone number", phone))
+			}
+		}
+	}
+
+	// thresholds, the per coin values fall back to the globals
+	filter := NewCoinFilter(config)
+	checkThreshold := func(name string, threshold feixiaohao.Threshold) {
+		if threshold.Low >= threshold.High {
+			problems = append(problems, fmt.Sprintf("%s: lowpricepercent %.2f should be below highpricepercent %.2f", name, threshold.Low, threshold.High))
+		}
+		if threshold.Amplitude <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: amplitude %.2f should be above 0", name, threshold.Amplitude))
+		}
+		if threshold.TimePeriod < 0 {
+			problems = append(problems, fmt.Sprintf("%s: notifytimeperiod should not be negative", name))
+		}
+	}
+	checkThreshold("global", filter.ThresholdOf(""))
+	overrides := false
+	for _, coin := range filter.CoinType {
+		spec, ok := filter.Specs[coin]
+		if !ok {
+			continue
+		}
+		checkThreshold(coin, filter.ThresholdOf(coin))
+		if spec.High != nil || spec.Low != nil || spec.Amplitude != nil || spec.Above != nil || spec.Below != nil {
+			overrides = true
+		}
+
+		// with rules the coin thresholds become extra rules, a period
+		// alone has no rule to apply to
+		if len(config.Rules) > 0 && spec.TimePeriod != nil && spec.High == nil && spec.Low == nil {
+			notes = append(notes, fmt.Sprintf("%s: notifytimeperiod only apply to the high and low set on the coin when rules are set", coin))
+		}
+	}
+	if len(config.Rules) > 0 && overrides {
+		notes = append(notes, "rules are set, the high, low, amplitude, above and below of a coin add to them")
+	}
+
+	// coins
+	if len(config.CoinTypes) == 0 {
+		if usesSource(config, "feixiaohao") && len(config.Sources) == 0 {
+			notes = append(notes, "cointype is empty, every coin of the feixiaohao watchlist is watched")
+		} else {
+			problems = append(problems, "cointype is empty")
+		}
+	}
+	return problems, notes
+}
+
+// usesSource tell if config read prices from the named source
+func usesSource(config *AppConfigOpt, name string) bool {
+	if len(config.Sources) == 0 {
+		return config.Source == name || (name == "feixiaohao" && config.Source == "")
+	}
+	if feixiaohao.HasSymbol(config.Sources, name) {
+		return true
+	}
+	for _, order := range config.CoinTypes.SourceOrders() {
+		if feixiaohao.HasSymbol(order, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShowConfig write config as yaml, every key labeled with the source of
+// its value and the secrets redacted
+func ShowConfig(w io.Writer, config *AppConfigOpt, sources map[string]string) error {
+	for _, field := range structs.New(config).Fields() {
+		name := strings.Split(field.Tag("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		value := redactURL(field.Value())
+		if secretField(field.Name()) && !field.IsZero() {
+			value = redacted
+		}
+		content, err := yaml.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+
+		text := strings.TrimRight(string(content), "\n")
+		source, ok := sources[field.Name()]
+		if !ok {
+			source = common.SourceDefault
+		}
+		label := "# " + source
+
+		// lists and maps go below the key unless they are empty
+		kind := reflect.ValueOf(value).Kind()
+		if (kind == reflect.Slice || kind == reflect.Map || kind == reflect.Struct) && !field.IsZero() {
+			fmt.Fprintf(w, "%s: %s\n", name, label)
+			for _, line := range strings.Split(text, "\n") {
+				fmt.Fprintf(w, "  %s\n", line)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "%s: %s %s\n", name, text, label)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/smileboywtu/CoinNotify/common"
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+)
+
+func validConfig(t *testing.T) *AppConfigOpt {
+	config := &AppConfigOpt{}
+	if err := common.ApplyDefaultValues(config); err != nil {
+		t.Fatal(err)
+	}
+	config.UserName = "user"
+	config.PassWD = "hunter2"
+	config.AccessKey = "key"
+	config.AccessID = "id"
+	config.SignName = "sign"
+	config.TemplateCode = "SMS_1"
+	config.NotifyPhone = "13800000000,+8613900000000"
+	config.CoinTypes = feixiaohao.CoinList{{Symbol: "BTC"}}
+	return config
+}
+
+func TestValidateConfig(t *testing.T) {
+	problems, notes := ValidateConfig(validConfig(t))
+	if len(problems) != 0 || len(notes) != 0 {
+		t.Fatal("valid config reported: ", problems, notes)
+	}
+
+	high := float32(-5)
+	config := validConfig(t)
+	config.AccessKey = ""
+	config.NotifyPhone = "138-0000"
+	config.CoinTypes = feixiaohao.CoinList{{Symbol: "ETH", High: &high}}
+	problems, _ = ValidateConfig(config)
+	text := strings.Join(problems, "\n")
+	for _, want := range []string{"aliyun sms need accesskey", `"138-0000" is not a phone number`, "ETH: lowpricepercent"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q in:\n%s", want, text)
+		}
+	}
+
+	config = validConfig(t)
+	config.CoinTypes = nil
+	problems, notes = ValidateConfig(config)
+	if len(problems) != 0 || len(notes) != 1 {
+		t.Fatal("empty cointype should only be noted for feixiaohao: ", problems, notes)
+	}
+	config.Source = "binance"
+	if problems, _ = ValidateConfig(config); len(problems) != 1 {
+		t.Fatal("empty cointype should fail without the watchlist: ", problems)
+	}
+}
+
+func TestShowConfig(t *testing.T) {
+	config := validConfig(t)
+	config.WebhookURL = "https://hooks.example.com/send?token=abc123"
+	sources := map[string]string{"PassWD": common.SourceEnv, "CoinTypes": common.SourceFile}
+
+	var out bytes.Buffer
+	if err := ShowConfig(&out, config, sources); err != nil {
+		t.Fatal(err)
+	}
+	text := out.String()
+	if strings.Contains(text, "hunter2") || !strings.Contains(text, "passwd: '******' # env") {
+		t.Fatal("password should be redacted: ", text)
+	}
+	if strings.Contains(text, "abc123") || !strings.Contains(text, "webhookurl: https://hooks.example.com/send?token=******") {
+		t.Fatal("webhook token should be redacted: ", text)
+	}
+	if !strings.Contains(text, "cointype: # file\n") || !strings.Contains(text, "  - BTC\n") {
+		t.Fatal("cointype should be listed: ", text)
+	}
+}
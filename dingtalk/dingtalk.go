@@ -0,0 +1,144 @@
+// Package dingtalk send alerts to a dingtalk group robot
+package dingtalk
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/notify"
+)
+
+const (
+	MsgTypeText     = "text"
+	MsgTypeMarkdown = "markdown"
+)
+
+type RobotOpt struct {
+	// Webhook is the robot url with access_token
+	Webhook string
+
+	// Secret enable the "加签" signature, starts with SEC
+	Secret  string
+	MsgType string
+}
+
+type Robot struct {
+	opts   RobotOpt
+	client *http.Client
+}
+
+type robotResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func NewRobot(opts RobotOpt) *Robot {
+	if opts.MsgType == "" {
+		opts.MsgType = MsgTypeText
+	}
+	return &Robot{
+		opts:   opts,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Sign return the signature of timestamp in milliseconds
+func Sign(secret string, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%d\n%s", timestamp, secret)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignedURL append timestamp and sign to the webhook when a secret is set
+func (r *Robot) SignedURL(now time.Time) (string, error) {
+	if r.opts.Secret == "" {
+		return r.opts.Webhook, nil
+	}
+
+	endpoint, err := url.Parse(r.opts.Webhook)
+	if err != nil {
+		return "", fmt.Errorf("invalid dingtalk webhook: %s", err)
+	}
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+	query := endpoint.Query()
+	query.Set("timestamp", strconv.FormatInt(timestamp, 10))
+	query.Set("sign", Sign(r.opts.Secret, timestamp))
+	endpoint.RawQuery = query.Encode()
+	return endpoint.String(), nil
+}
+
+// Message build the robot message of alert
+func (r *Robot) Message(alert notify.Alert) map[string]interface{} {
+	if alert.Warning() {
+		return map[string]interface{}{
+			"msgtype": MsgTypeText,
+			"text":    map[string]string{"content": fmt.Sprintf("价格源%s告警，币种：%s，%s", alert.Platform, alert.CoinType, alert.Reason)},
+		}
+	}
+	if r.opts.MsgType == MsgTypeMarkdown {
+		text := fmt.Sprintf("### %s 价格提醒\n\n- 交易平台: %s\n- 当前价格: %s\n- 浮动: %s\n",
+			alert.CoinType, alert.Platform, alert.Price, alert.Percent)
+		if alert.Reason != "" {
+			text += fmt.Sprintf("- 原因: %s\n", alert.Reason)
+		}
+		return map[string]interface{}{
+			"msgtype": MsgTypeMarkdown,
+			"markdown": map[string]string{
+				"title": alert.CoinType + " 价格提醒",
+				"text":  text,
+			},
+		}
+	}
+
+	content := fmt.Sprintf("交易平台%s，币种：%s，当前价格：%s，浮动：%s",
+		alert.Platform, alert.CoinType, alert.Price, alert.Percent)
+	if alert.Reason != "" {
+		content += "，原因：" + alert.Reason
+	}
+	return map[string]interface{}{
+		"msgtype": MsgTypeText,
+		"text":    map[string]string{"content": content},
+	}
+}
+
+func (r *Robot) Notify(alert notify.Alert) error {
+	if r.opts.Webhook == "" {
+		return fmt.Errorf("dingtalk webhook is empty")
+	}
+
+	endpoint, err := r.SignedURL(time.Now())
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(r.Message(alert))
+	if err != nil {
+		return err
+	}
+
+	response, err := r.client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// the url contains the token, keep it out of logs
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return fmt.Errorf("dingtalk http error: %s", err)
+	}
+	defer response.Body.Close()
+
+	var result robotResponse
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return fmt.Errorf("dingtalk response error: %s, %s", response.Status, err)
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("dingtalk send fails: %d %s", result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package dingtalk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/smileboywtu/CoinNotify/notify"
+)
+
+func TestNotify(t *testing.T) {
+	var message map[string]map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		timestamp, _ := strconv.ParseInt(query.Get("timestamp"), 10, 64)
+		if query.Get("access_token") != "abc" || query.Get("sign") != Sign("SECret", timestamp) {
+			fmt.Fprint(w, `{"errcode": 310000, "errmsg": "sign not match"}`)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&message)
+		fmt.Fprint(w, `{"errcode": 0, "errmsg": "ok"}`)
+	}))
+	defer server.Close()
+
+	alert := notify.Alert{CoinType: "CMT", Platform: "Bittrex", Price: "0.52", Percent: "5.2%"}
+	robot := NewRobot(RobotOpt{Webhook: server.URL + "?access_token=abc", Secret: "SECret", MsgType: MsgTypeMarkdown})
+	if err := robot.Notify(alert); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(message["markdown"]["text"], "5.2%") {
+		t.Fatal("bad markdown message: ", message)
+	}
+
+	warning := notify.Alert{Kind: notify.KindWarning, CoinType: "CMT", Platform: "feixiaohao", Reason: "price source failing"}
+	if err := robot.Notify(warning); err != nil {
+		t.Fatal(err)
+	}
+	if text := message["text"]["content"]; !strings.Contains(text, "price source failing") || strings.Contains(text, "浮动") {
+		t.Fatal("warning should render the reason without price: ", message)
+	}
+
+	unsigned := NewRobot(RobotOpt{Webhook: server.URL + "?access_token=abc"})
+	if err := unsigned.Notify(alert); err == nil {
+		t.Fatal("robot error code should be reported")
+	}
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	if err := NewRobot(RobotOpt{Webhook: closed.URL + "?access_token=abc"}).Notify(alert); err == nil || strings.Contains(err.Error(), "abc") {
+		t.Fatal("http error should not leak the token: ", err)
+	}
+}
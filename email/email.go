@@ -0,0 +1,225 @@
+// Package email send alert digests over smtp
+package email
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/notify"
+)
+
+// DefaultSubject is used when no subject is configured
+const DefaultSubject = "Coin Price Notify"
+
+type EmailOpt struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+
+	From    string
+	To      []string
+	Subject string
+
+	// InsecureSkipVerify disable the server certificate check of STARTTLS
+	InsecureSkipVerify bool
+
+	// AllowPlaintext send the auth and the mail without TLS when the
+	// server offer no STARTTLS, otherwise sending with auth fail
+	AllowPlaintext bool
+}
+
+// Notifier mail every triggered coin of one task round in one digest
+type Notifier struct {
+	Opts EmailOpt
+}
+
+func NewNotifier(opts EmailOpt) *Notifier {
+	if opts.Port == 0 {
+		opts.Port = 587
+	}
+	if opts.Subject == "" {
+		opts.Subject = DefaultSubject
+	}
+	if opts.From == "" {
+		opts.From = opts.Username
+	}
+	return &Notifier{Opts: opts}
+}
+
+func (n *Notifier) Notify(alert notify.Alert) error {
+	return n.NotifyBatch([]notify.Alert{alert})
+}
+
+func (n *Notifier) NotifyBatch(alerts []notify.Alert) error {
+	if n.Opts.Host == "" || len(n.Opts.To) == 0 {
+		return fmt.Errorf("email host or receiver is empty")
+	}
+
+	message, err := BuildMessage(n.Opts, alerts, time.Now())
+	if err != nil {
+		return err
+	}
+	return n.send(message)
+}
+
+// send deliver message with STARTTLS and PLAIN auth
+func (n *Notifier) send(message []byte) error {
+	addr := net.JoinHostPort(n.Opts.Host, strconv.Itoa(n.Opts.Port))
+	client, err := smtp.Dial(addr)
+	if err != nil {
+		return fmt.Errorf("smtp dial error: %s", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		config := &tls.Config{
+			ServerName:         n.Opts.Host,
+			InsecureSkipVerify: n.Opts.InsecureSkipVerify,
+		}
+		if err := client.StartTLS(config); err != nil {
+			return fmt.Errorf("smtp starttls error: %s", err)
+		}
+	} else if n.Opts.Username != "" && !n.Opts.AllowPlaintext {
+		return fmt.Errorf("smtp server %s offer no STARTTLS, refuse to send the password in plaintext", n.Opts.Host)
+	}
+
+	if n.Opts.Username != "" {
+		var auth smtp.Auth = smtp.PlainAuth("", n.Opts.Username, n.Opts.Password, n.Opts.Host)
+		if n.Opts.AllowPlaintext {
+			auth = plaintextAuth{auth}
+		}
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("smtp auth error: %s", err)
+		}
+	}
+
+	if err := client.Mail(n.Opts.From); err != nil {
+		return fmt.Errorf("smtp mail from error: %s", err)
+	}
+	for _, to := range n.Opts.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp rcpt %s error: %s", to, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data error: %s", err)
+	}
+	if _, err := writer.Write(message); err != nil {
+		return fmt.Errorf("smtp write error: %s", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("smtp send error: %s", err)
+	}
+	return client.Quit()
+}
+
+// plaintextAuth let PLAIN auth run without TLS, PlainAuth refuse it unless
+// the server is localhost
+type plaintextAuth struct {
+	smtp.Auth
+}
+
+func (a plaintextAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	info := *server
+	info.TLS = true
+	return a.Auth.Start(&info)
+}
+
+var htmlTemplate = template.Must(template.New("digest").Parse(`<html>
+<body>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Coin</th><th>Platform</th><th>Price</th><th>Percent</th><th>Reason</th></tr>
+{{range .}}<tr><td>{{.CoinType}}</td><td>{{.Platform}}</td><td>{{.Price}}</td><td>{{.Percent}}</td><td>{{.Reason}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// PlainText render alerts as an aligned text table
+func PlainText(alerts []notify.Alert) string {
+	var buf bytes.Buffer
+	writer := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "Coin\tPlatform\tPrice\tPercent\tReason")
+	for _, alert := range alerts {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", alert.CoinType, alert.Platform, alert.Price, alert.Percent, alert.Reason)
+	}
+	writer.Flush()
+	return buf.String()
+}
+
+// HTML render alerts as a html table
+func HTML(alerts []notify.Alert) (string, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, alerts); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// BuildMessage create a multipart/alternative mail with a text and a html body
+func BuildMessage(opts EmailOpt, alerts []notify.Alert, now time.Time) ([]byte, error) {
+	html, err := HTML(alerts)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	subject := opts.Subject
+	if len(alerts) > 0 {
+		coins := make([]string, 0, len(alerts))
+		for _, alert := range alerts {
+			coins = append(coins, alert.CoinType)
+		}
+		subject = fmt.Sprintf("%s: %s", subject, strings.Join(coins, ", "))
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", opts.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(opts.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", PlainText(alerts)},
+		{"text/html; charset=utf-8", html},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		partWriter, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(partWriter)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		qp.Close()
+	}
+	writer.Close()
+
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
@@ -0,0 +1,145 @@
+package email
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/notify"
+)
+
+func TestBuildMessage(t *testing.T) {
+	alerts := []notify.Alert{
+		{CoinType: "CMT", Platform: "Bittrex", Price: "¥0.52", Percent: "5.2%", Reason: "first notify"},
+		{CoinType: "IOST", Platform: "Binance", Price: "¥0.11", Percent: "-3.1%", Reason: "percent below -2.00%"},
+	}
+	opts := EmailOpt{From: "bot@example.com", To: []string{"a@example.com", "b@example.com"}, Subject: DefaultSubject}
+
+	raw, err := BuildMessage(opts, alerts, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Coin Price Notify: CMT, IOST" {
+		t.Fatal("bad subject: ", subject)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatal("bad content type: ", msg.Header.Get("Content-Type"))
+	}
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	types := []string{}
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		content, _ := ioutil.ReadAll(quotedprintable.NewReader(part))
+		for _, alert := range alerts {
+			if !strings.Contains(string(content), alert.CoinType) || !strings.Contains(string(content), alert.Percent) {
+				t.Fatal("part miss alert ", alert.CoinType, ": ", string(content))
+			}
+		}
+		types = append(types, strings.Split(part.Header.Get("Content-Type"), ";")[0])
+	}
+	if strings.Join(types, ",") != "text/plain,text/html" {
+		t.Fatal("bad parts: ", types)
+	}
+}
+
+// fakeSMTP answer one session without STARTTLS and record the commands
+func fakeSMTP(t *testing.T) (addr string, commands <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan string, 32)
+	go func() {
+		defer listener.Close()
+		defer close(received)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 fake ESMTP")
+		inData := false
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == "." {
+					inData = false
+					text.PrintfLine("250 queued")
+				}
+				continue
+			}
+			command := strings.ToUpper(strings.Fields(line + " ")[0])
+			received <- command
+			switch command {
+			case "EHLO":
+				text.PrintfLine("250-fake\r\n250 AUTH PLAIN")
+			case "AUTH":
+				text.PrintfLine("235 ok")
+			case "DATA":
+				inData = true
+				text.PrintfLine("354 go on")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				return
+			default:
+				text.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestSendRequireTLS(t *testing.T) {
+	for _, plaintext := range []bool{false, true} {
+		addr, commands := fakeSMTP(t)
+		host, port, _ := net.SplitHostPort(addr)
+		portNumber, _ := strconv.Atoi(port)
+		notifier := NewNotifier(EmailOpt{
+			Host:           host,
+			Port:           portNumber,
+			Username:       "bob",
+			Password:       "hunter2",
+			To:             []string{"bob@example.com"},
+			AllowPlaintext: plaintext,
+		})
+
+		err := notifier.Notify(notify.Alert{CoinType: "BTC"})
+		var seen []string
+		for command := range commands {
+			seen = append(seen, command)
+		}
+		sent := strings.Contains(strings.Join(seen, " "), "AUTH")
+		if plaintext && (err != nil || !sent) {
+			t.Fatal("plaintext allowed should send: ", err, seen)
+		}
+		if !plaintext && (err == nil || sent) {
+			t.Fatal("auth without STARTTLS should fail: ", err, seen)
+		}
+	}
+}
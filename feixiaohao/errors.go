@@ -0,0 +1,136 @@
+package feixiaohao
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// LayoutError mean the userticker markup is not what the parser know,
+// the page changed and the parser need an update
+type LayoutError struct {
+	Reason string
+}
+
+func (e *LayoutError) Error() string {
+	return "feixiaohao page layout changed: " + e.Reason
+}
+
+// SessionExpiredError mean the server answered with a logged out page
+type SessionExpiredError struct {
+	Reason string
+}
+
+func (e *SessionExpiredError) Error() string {
+	return "feixiaohao session expired: " + e.Reason
+}
+
+// NotWatchedError mean configured coins are not in the watchlist of the
+// user, the page itself is fine
+type NotWatchedError struct {
+	Coins []string
+	// Source is the source missing the coins, empty for the feixiaohao
+	// watchlist
+	Source string
+}
+
+func (e *NotWatchedError) Error() string {
+	if e.Source != "" {
+		return fmt.Sprintf("coin not found on %s: %s", e.Source, strings.Join(e.Coins, ", "))
+	}
+	return fmt.Sprintf("coin not in feixiaohao watchlist: %s", strings.Join(e.Coins, ", "))
+}
+
+// AmbiguousError mean a cointype entry select several rows, qualify it
+// with the platform like CMT@Binance to pick one
+type AmbiguousError struct {
+	Entry string
+	Rows  []string
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("%s match several rows: %s", e.Entry, strings.Join(e.Rows, ", "))
+}
+
+// CellError is a table cell that can not be parsed, the row is skipped
+type CellError struct {
+	Coin   string
+	Column string
+	Text   string
+}
+
+func (e *CellError) Error() string {
+	return fmt.Sprintf("can not parse %s of %s: %q", e.Column, e.Coin, e.Text)
+}
+
+// LayoutChanged tell if err, or one of the errors it combine, come from a
+// page layout change, unparsable cells count as a change
+func LayoutChanged(err error) bool {
+	return findError(err, func(err error) bool {
+		switch err.(type) {
+		case *LayoutError, *CellError:
+			return true
+		}
+		return false
+	})
+}
+
+// NotWatched tell if err, or one of the errors it combine, is a coin
+// missing from the watchlist
+func NotWatched(err error) bool {
+	return findError(err, func(err error) bool {
+		_, ok := err.(*NotWatchedError)
+		return ok
+	})
+}
+
+// Ambiguous tell if err, or one of the errors it combine, is an entry
+// selecting several rows
+func Ambiguous(err error) bool {
+	return findError(err, func(err error) bool {
+		_, ok := err.(*AmbiguousError)
+		return ok
+	})
+}
+
+// Partial tell if err only report coins missing from the watchlist or
+// selecting several rows, the records of the other coins were read. An
+// error with a Partial method answer for itself
+func Partial(err error) bool {
+	if partial, ok := err.(interface{ Partial() bool }); ok {
+		return partial.Partial()
+	}
+	if wrapper, ok := err.(interface{ Unwrap() error }); ok {
+		return Partial(wrapper.Unwrap())
+	}
+	if merr, ok := err.(*multierror.Error); ok {
+		for _, err := range merr.Errors {
+			if !Partial(err) {
+				return false
+			}
+		}
+		return len(merr.Errors) > 0
+	}
+	switch err.(type) {
+	case *NotWatchedError, *AmbiguousError:
+		return true
+	}
+	return false
+}
+
+// findError look into the combined and the wrapped errors of err too
+func findError(err error, match func(error) bool) bool {
+	if wrapper, ok := err.(interface{ Unwrap() error }); ok {
+		return findError(wrapper.Unwrap(), match)
+	}
+	if merr, ok := err.(*multierror.Error); ok {
+		for _, err := range merr.Errors {
+			if findError(err, match) {
+				return true
+			}
+		}
+		return false
+	}
+	return err != nil && match(err)
+}
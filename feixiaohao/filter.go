@@ -0,0 +1,174 @@
+package feixiaohao
+
+import (
+	"fmt"
+
+	"github.com/smileboywtu/CoinNotify/price"
+)
+
+// CoinSpec is one watched coin, in config it is a plain symbol or a map
+// with its own thresholds:
+//
+//	cointype:
+//	  - CMT
+//	  - symbol: IOST
+//	    high: 8.0
+//	    low: -5.0
+//	  - BTC: {amplitude: 0.5, above: "¥60000", below: "¥40000"}
+//	  - ETH: {sources: [binance, coingecko]}
+//
+// unset thresholds fall back to the global values of CoinFilter, above
+// and below are absolute price levels that alert once per crossing,
+// sources is the failover order of the coin
+type CoinSpec struct {
+	Symbol     string   `yaml:"symbol"`
+	High       *float32 `yaml:"high,omitempty"`
+	Low        *float32 `yaml:"low,omitempty"`
+	Amplitude  *float32 `yaml:"amplitude,omitempty"`
+	TimePeriod *int64   `yaml:"notifytimeperiod,omitempty"`
+
+	Above *price.Value `yaml:"above,omitempty"`
+	Below *price.Value `yaml:"below,omitempty"`
+
+	Sources []string `yaml:"sources,omitempty"`
+}
+
+type coinThreshold struct {
+	High       *float32     `yaml:"high"`
+	Low        *float32     `yaml:"low"`
+	Amplitude  *float32     `yaml:"amplitude"`
+	TimePeriod *int64       `yaml:"notifytimeperiod"`
+	Above      *price.Value `yaml:"above"`
+	Below      *price.Value `yaml:"below"`
+	Sources    []string     `yaml:"sources"`
+}
+
+func (c *CoinSpec) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var symbol string
+	if err := unmarshal(&symbol); err == nil {
+		c.Symbol = symbol
+		return nil
+	}
+
+	// symbol: CMT form
+	type plain CoinSpec
+	var spec plain
+	if err := unmarshal(&spec); err == nil && spec.Symbol != "" {
+		*c = CoinSpec(spec)
+		return nil
+	}
+
+	// CMT: {high: 5} form
+	var short map[string]coinThreshold
+	if err := unmarshal(&short); err != nil || len(short) != 1 {
+		return fmt.Errorf("cointype entry should be a symbol or a map with symbol and thresholds")
+	}
+	for symbol, threshold := range short {
+		*c = CoinSpec{
+			Symbol:     symbol,
+			High:       threshold.High,
+			Low:        threshold.Low,
+			Amplitude:  threshold.Amplitude,
+			TimePeriod: threshold.TimePeriod,
+			Above:      threshold.Above,
+			Below:      threshold.Below,
+			Sources:    threshold.Sources,
+		}
+	}
+	return nil
+}
+
+// MarshalYAML write coin without thresholds back as a plain symbol
+func (c CoinSpec) MarshalYAML() (interface{}, error) {
+	if c.High == nil && c.Low == nil && c.Amplitude == nil && c.TimePeriod == nil && c.Above == nil && c.Below == nil && len(c.Sources) == 0 {
+		return c.Symbol, nil
+	}
+	type plain CoinSpec
+	return plain(c), nil
+}
+
+// CoinList is the cointype config
+type CoinList []CoinSpec
+
+// Symbols return the coin symbols in config order
+func (l CoinList) Symbols() []string {
+	symbols := make([]string, 0, len(l))
+	for _, spec := range l {
+		symbols = append(symbols, spec.Symbol)
+	}
+	return symbols
+}
+
+// SetStrings replace the list with plain symbols, it bind the cointype
+// flag and env var
+func (l *CoinList) SetStrings(symbols []string) error {
+	list := make(CoinList, 0, len(symbols))
+	for _, symbol := range symbols {
+		list = append(list, CoinSpec{Symbol: symbol})
+	}
+	*l = list
+	return nil
+}
+
+// Specs index the coins by symbol
+func (l CoinList) Specs() map[string]CoinSpec {
+	specs := make(map[string]CoinSpec, len(l))
+	for _, spec := range l {
+		specs[spec.Symbol] = spec
+	}
+	return specs
+}
+
+// SourceOrders return the failover order of the coins that set one
+func (l CoinList) SourceOrders() map[string][]string {
+	orders := make(map[string][]string)
+	for _, spec := range l {
+		if len(spec.Sources) > 0 {
+			orders[spec.Symbol] = spec.Sources
+		}
+	}
+	return orders
+}
+
+// Threshold is the resolved notify thresholds of one coin
+type Threshold struct {
+	High       float32
+	Low        float32
+	Amplitude  float32
+	TimePeriod int64
+
+	// Above and Below are the price levels, nil when not set
+	Above *price.Value
+	Below *price.Value
+}
+
+// ThresholdOf return the thresholds of coin, per coin values override the globals
+func (f CoinFilter) ThresholdOf(coin string) Threshold {
+	threshold := Threshold{
+		High:       f.High,
+		Low:        f.Low,
+		Amplitude:  f.Amplitude,
+		TimePeriod: f.TimePeriod,
+	}
+
+	spec, ok := f.Specs[coin]
+	if !ok {
+		return threshold
+	}
+
+	if spec.High != nil {
+		threshold.High = *spec.High
+	}
+	if spec.Low != nil {
+		threshold.Low = *spec.Low
+	}
+	if spec.Amplitude != nil {
+		threshold.Amplitude = *spec.Amplitude
+	}
+	if spec.TimePeriod != nil {
+		threshold.TimePeriod = *spec.TimePeriod
+	}
+	threshold.Above = spec.Above
+	threshold.Below = spec.Below
+	return threshold
+}
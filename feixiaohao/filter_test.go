@@ -0,0 +1,73 @@
+package feixiaohao
+
+import (
+	"testing"
+
+	"github.com/smileboywtu/CoinNotify/price"
+	"gopkg.in/yaml.v2"
+)
+
+func TestCoinListYaml(t *testing.T) {
+	content := `
+cointype:
+  - CMT
+  - symbol: IOST
+    high: 8.0
+    low: -5.0
+  - BTC: {amplitude: 0.5, notifytimeperiod: 60, above: "¥60,000", below: 4万}
+`
+	var config struct {
+		CoinTypes CoinList `yaml:"cointype"`
+	}
+	if err := yaml.Unmarshal([]byte(content), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	symbols := config.CoinTypes.Symbols()
+	if len(symbols) != 3 || symbols[0] != "CMT" || symbols[1] != "IOST" || symbols[2] != "BTC" {
+		t.Fatal("bad symbols: ", symbols)
+	}
+
+	filter := CoinFilter{
+		CoinType:   symbols,
+		High:       3,
+		Low:        -2,
+		Amplitude:  1,
+		TimePeriod: 3600,
+		Specs:      config.CoinTypes.Specs(),
+	}
+
+	if threshold := filter.ThresholdOf("CMT"); threshold != (Threshold{High: 3, Low: -2, Amplitude: 1, TimePeriod: 3600}) {
+		t.Fatal("CMT should use the globals: ", threshold)
+	}
+	if threshold := filter.ThresholdOf("IOST"); threshold != (Threshold{High: 8, Low: -5, Amplitude: 1, TimePeriod: 3600}) {
+		t.Fatal("IOST should override high and low: ", threshold)
+	}
+	threshold := filter.ThresholdOf("BTC")
+	if threshold.Amplitude != 0.5 || threshold.TimePeriod != 60 || threshold.High != 3 {
+		t.Fatal("BTC should override amplitude and period: ", threshold)
+	}
+	if threshold.Above == nil || *threshold.Above != (price.Value{Amount: 60000, Currency: "CNY"}) {
+		t.Fatal("bad above level: ", threshold.Above)
+	}
+	if threshold.Below == nil || threshold.Below.Amount != 40000 {
+		t.Fatal("bad below level: ", threshold.Below)
+	}
+
+	// plain entries are written back as plain symbols
+	out, err := yaml.Marshal(config.CoinTypes[:1])
+	if err != nil || string(out) != "- CMT\n" {
+		t.Fatal("bad marshal: ", string(out), err)
+	}
+
+	if err := yaml.Unmarshal([]byte("cointype:\n  - ETH: {sources: [binance, coingecko]}\n"), &config); err != nil {
+		t.Fatal(err)
+	}
+	if orders := config.CoinTypes.SourceOrders(); len(orders["ETH"]) != 2 || orders["ETH"][0] != "binance" {
+		t.Fatal("bad source order: ", orders)
+	}
+
+	if err := yaml.Unmarshal([]byte("cointype:\n  - [CMT]\n"), &config); err == nil {
+		t.Fatal("list entry should fail")
+	}
+}
@@ -24,6 +24,11 @@ type CoinFilter struct {
 	Low        float32
 	Amplitude  float32
 	TimePeriod int64
+
+	// Specs hold the per coin thresholds by symbol
+	Specs      map[string]CoinSpec
+	// Aliases map other names of coins to their symbols
+	Aliases    map[string]string
 }
 
 type CoinPriceMeta struct {
@@ -31,6 +36,19 @@ type CoinPriceMeta struct {
 	Price    string
 	Percent  string
 	CoinType string
+
+	// Volume is the 24h trade volume, empty when the source has none
+	Volume   string
+	// Sources list the platforms that agreed on an aggregated record
+	Sources  string
+
+	// the columns below are only filled by sources that show them
+	Rank      string
+	MarketCap string
+	// Turnover is the 24h turnover rate
+	Turnover  string
+	Change1h  string
+	Change7d  string
 }
 
 func Login(user UserLoginMeta) ([]*http.Cookie, error) {
@@ -38,7 +56,7 @@ func Login(user UserLoginMeta) ([]*http.Cookie, error) {
 	client := gorequest.New()
 	formstring, errs := json.Marshal(user)
 	if errs != nil {
-		return nil, errors.New(fmt.Sprintf("parse user content error: %s", user))
+		return nil, errors.New(fmt.Sprintf("parse user content error: %v", user))
 	}
 	// create cookie jar
 	response, body, err := client.Post("https://api.feixiaohao.com/user/login").
@@ -60,55 +78,45 @@ func Login(user UserLoginMeta) ([]*http.Cookie, error) {
 	return (*http.Response)(response).Cookies(), nil
 }
 
-func GetUserTicket(cookies []*http.Cookie, filter CoinFilter) ([]CoinPriceMeta, error) {
+// userTickerURL is the watchlist page
+var userTickerURL = "https://www.feixiaohao.com/userticker/"
 
-	metas := make([]CoinPriceMeta, 0, len(filter.CoinType))
+// GetUserTicket read the watched coins from the watchlist page, a logged
+// out answer return a *SessionExpiredError
+func GetUserTicket(cookies []*http.Cookie, filter CoinFilter) ([]CoinPriceMeta, error) {
 
 	client := gorequest.New()
 
-	response, _, errs := client.Get("https://www.feixiaohao.com/userticker/").
+	response, _, errs := client.Get(userTickerURL).
 		AddCookies(cookies).
 		Timeout(15 * time.Second).
 		End()
-	if response.StatusCode != 200 || errs != nil {
+	if errs != nil {
 		return nil, errors.New(fmt.Sprintf("get user ticket error: %s", errs))
 	}
+	if response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden {
+		return nil, &SessionExpiredError{Reason: response.Status}
+	}
+	if response.StatusCode != 200 {
+		return nil, errors.New(fmt.Sprintf("get user ticket error: %s", response.Status))
+	}
+	// the page redirect to the login page when the cookies expired
+	if response.Request != nil && strings.Contains(response.Request.URL.Path, "login") {
+		return nil, &SessionExpiredError{Reason: "redirected to " + response.Request.URL.Path}
+	}
 	query, err := goquery.NewDocumentFromReader(response.Body)
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("parse html error: %s", err))
 	}
-	query.Find(".new-table.new-table-custom#table tbody>tr").Each(func(i int, selection *goquery.Selection) {
-
-		var text string
-		if StringListContains(filter.CoinType, selection.Find("td").Eq(1).Text()) {
-			var newNode = CoinPriceMeta{}
-			for _, value := range []int{1, 2, 3, 6} {
-				text = strings.TrimSpace(selection.Find("td").Eq(value).Text())
-				if value == 1 {
-					newNode.CoinType = text
-				} else if value == 2 {
-					newNode.Platform = text
-				} else if value == 3 {
-					newNode.Price = text
-				} else if value == 6 {
-					newNode.Percent = text
-				}
-			}
-			metas = append(metas, newNode)
-		}
-
-	})
-
-	return metas, nil
-}
 
-// StringListContains check if elements in array
-func StringListContains(array []string, element string) bool {
-
-	for _, value := range array {
-		if strings.Contains(element, value) {
-			return true
-		}
+	metas, err := ParseTicketTable(query, filter)
+	if _, layout := err.(*LayoutError); layout && loggedOut(query) {
+		return nil, &SessionExpiredError{Reason: "login form instead of watchlist"}
 	}
-	return false
+	return metas, err
+}
+
+// loggedOut tell if the page ask for a login
+func loggedOut(document *goquery.Document) bool {
+	return document.Find(`input[type="password"], form[action*="login"]`).Length() > 0
 }
@@ -0,0 +1,175 @@
+package feixiaohao
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/store"
+)
+
+// DefaultSessionLifetime is used when the login cookies carry no expiry
+const DefaultSessionLifetime = 12 * time.Hour
+
+// login backoff after failed logins
+const (
+	loginBackoff    = 30 * time.Second
+	maxLoginBackoff = 30 * time.Minute
+)
+
+// sessionCache is the content of the cookie cache file
+type sessionCache struct {
+	Cookies []*http.Cookie `json:"cookies"`
+	Expires time.Time      `json:"expires"`
+}
+
+// Session hold the login cookies of a user, login on demand and keep the
+// cookies in CacheFile across restarts. It is safe for concurrent use
+type Session struct {
+	User UserLoginMeta
+	// CacheFile is the cookie cache path, empty to keep cookies in memory
+	CacheFile string
+
+	lock     sync.Mutex
+	cookies  []*http.Cookie
+	expires  time.Time
+	failures int
+	retryAt  time.Time
+	lastErr  error
+
+	login func(UserLoginMeta) ([]*http.Cookie, error)
+	now   func() time.Time
+}
+
+// NewSession create a session of user, the cached cookies are loaded when
+// they are still valid
+func NewSession(user UserLoginMeta, cacheFile string) *Session {
+	session := &Session{
+		User:      user,
+		CacheFile: cacheFile,
+		login:     Login,
+		now:       time.Now,
+	}
+	session.load()
+	return session
+}
+
+func (s *Session) load() {
+	if s.CacheFile == "" {
+		return
+	}
+	content, err := ioutil.ReadFile(s.CacheFile)
+	if err != nil {
+		return
+	}
+	var cache sessionCache
+	if err := json.Unmarshal(content, &cache); err != nil {
+		// a broken cache only cost a login
+		os.Remove(s.CacheFile)
+		return
+	}
+	if len(cache.Cookies) > 0 && s.now().Before(cache.Expires) {
+		s.cookies, s.expires = cache.Cookies, cache.Expires
+	}
+}
+
+func (s *Session) save() error {
+	if s.CacheFile == "" {
+		return nil
+	}
+	content, err := json.MarshalIndent(sessionCache{Cookies: s.cookies, Expires: s.expires}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFile(s.CacheFile, content)
+}
+
+// Cookies return the session cookies, login first when the session is
+// missing or expired. After a failed login it fail fast until the backoff
+// pass
+func (s *Session) Cookies() ([]*http.Cookie, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.cookies) > 0 && s.now().Before(s.expires) {
+		return s.cookies, nil
+	}
+	if err := s.relogin(); err != nil {
+		return nil, err
+	}
+	return s.cookies, nil
+}
+
+// Expires return the expiry of the current cookies, zero when logged out
+func (s *Session) Expires() time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.cookies) == 0 {
+		return time.Time{}
+	}
+	return s.expires
+}
+
+// Invalidate drop the cookies after the server reject them, the next call
+// of Cookies login again
+func (s *Session) Invalidate() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.cookies, s.expires = nil, time.Time{}
+	if s.CacheFile != "" {
+		os.Remove(s.CacheFile)
+	}
+}
+
+// Renew login now even if the cookies are still valid
+func (s *Session) Renew() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.relogin()
+}
+
+// relogin login with backoff, the caller hold the lock
+func (s *Session) relogin() error {
+	now := s.now()
+	if now.Before(s.retryAt) {
+		return fmt.Errorf("feixiaohao login backoff until %s: %s", s.retryAt.Format("15:04:05"), s.lastErr)
+	}
+
+	cookies, err := s.login(s.User)
+	if err != nil {
+		s.failures++
+		backoff := loginBackoff << uint(s.failures-1)
+		if backoff > maxLoginBackoff || backoff <= 0 {
+			backoff = maxLoginBackoff
+		}
+		s.retryAt, s.lastErr = now.Add(backoff), err
+		return err
+	}
+
+	s.failures, s.retryAt, s.lastErr = 0, time.Time{}, nil
+	s.cookies, s.expires = cookies, cookieExpiry(cookies, now)
+	if err := s.save(); err != nil {
+		fmt.Printf("save session error: %s\n", err)
+	}
+	return nil
+}
+
+// cookieExpiry return the earliest expiry of cookies, cookies already
+// expired are deletions and do not count
+func cookieExpiry(cookies []*http.Cookie, now time.Time) time.Time {
+	expires := now.Add(DefaultSessionLifetime)
+	for _, cookie := range cookies {
+		at := cookie.Expires
+		if cookie.MaxAge > 0 {
+			at = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+		if at.After(now) && at.Before(expires) {
+			expires = at
+		}
+	}
+	return expires
+}
@@ -0,0 +1,162 @@
+package feixiaohao
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fakeLogin struct {
+	calls int
+	err   error
+}
+
+func (f *fakeLogin) login(user UserLoginMeta) ([]*http.Cookie, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []*http.Cookie{{Name: "token", Value: fmt.Sprintf("t%d", f.calls), MaxAge: 3600}}, nil
+}
+
+func newTestSession(t *testing.T, login *fakeLogin, now *time.Time) (*Session, string) {
+	dir, err := ioutil.TempDir("", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := &Session{
+		CacheFile: filepath.Join(dir, "session.json"),
+		login:     login.login,
+		now:       func() time.Time { return *now },
+	}
+	return session, dir
+}
+
+func TestSessionCache(t *testing.T) {
+	now := time.Now()
+	login := &fakeLogin{}
+	session, dir := newTestSession(t, login, &now)
+	defer os.RemoveAll(dir)
+
+	cookies, err := session.Cookies()
+	if err != nil || cookies[0].Value != "t1" {
+		t.Fatal("first call should login: ", cookies, err)
+	}
+	if _, err := session.Cookies(); err != nil || login.calls != 1 {
+		t.Fatal("valid cookies should be reused: ", login.calls)
+	}
+
+	// a new process read the cache
+	restored := NewSession(UserLoginMeta{}, session.CacheFile)
+	restored.login = login.login
+	if cookies, err := restored.Cookies(); err != nil || cookies[0].Value != "t1" || login.calls != 1 {
+		t.Fatal("cached cookies should be reused: ", cookies, err)
+	}
+
+	// max age passed
+	now = now.Add(2 * time.Hour)
+	if cookies, _ := session.Cookies(); cookies[0].Value != "t2" {
+		t.Fatal("expired session should login again: ", cookies)
+	}
+
+	session.Invalidate()
+	if _, err := os.Stat(session.CacheFile); !os.IsNotExist(err) {
+		t.Fatal("invalidate should drop the cache")
+	}
+	if cookies, _ := session.Cookies(); cookies[0].Value != "t3" {
+		t.Fatal("invalidated session should login again: ", cookies)
+	}
+}
+
+func TestSessionBackoff(t *testing.T) {
+	now := time.Now()
+	login := &fakeLogin{err: errors.New("bad password")}
+	session, dir := newTestSession(t, login, &now)
+	defer os.RemoveAll(dir)
+
+	if _, err := session.Cookies(); err == nil {
+		t.Fatal("expect login error")
+	}
+	if _, err := session.Cookies(); err == nil || login.calls != 1 {
+		t.Fatal("login should wait the backoff: ", login.calls)
+	}
+
+	now = now.Add(loginBackoff)
+	session.Cookies()
+	if login.calls != 2 {
+		t.Fatal("login should retry after backoff: ", login.calls)
+	}
+
+	// backoff doubled
+	now = now.Add(loginBackoff)
+	session.Cookies()
+	if login.calls != 2 {
+		t.Fatal("second backoff should be longer: ", login.calls)
+	}
+
+	login.err = nil
+	now = now.Add(loginBackoff)
+	if _, err := session.Cookies(); err != nil || login.calls != 3 {
+		t.Fatal("login should recover: ", err)
+	}
+}
+
+func TestSourceRelogin(t *testing.T) {
+	page, err := ioutil.ReadFile("testdata/userticker.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Write([]byte(`<form action="/login"><input type="password" name="password"></form>`))
+		default:
+			if cookie, err := r.Cookie("token"); err != nil || cookie.Value == "t1" {
+				http.Redirect(w, r, "/login", http.StatusFound)
+				return
+			}
+			w.Write(page)
+		}
+	}))
+	defer server.Close()
+
+	defer func(url string) { userTickerURL = url }(userTickerURL)
+	userTickerURL = server.URL + "/userticker/"
+
+	now := time.Now()
+	login := &fakeLogin{}
+	session, dir := newTestSession(t, login, &now)
+	defer os.RemoveAll(dir)
+
+	// the first token is rejected by the server
+	metas, err := NewSource(session).Fetch(CoinFilter{CoinType: []string{"BTC"}})
+	if err != nil || len(metas) != 1 {
+		t.Fatal("fetch should login again: ", metas, err)
+	}
+	if login.calls != 2 {
+		t.Fatal("expect one relogin: ", login.calls)
+	}
+
+	// a page without the table login again only once per window
+	page = []byte(`<html><body>maintenance</body></html>`)
+	source := NewSource(session)
+	for i := 0; i < 3; i++ {
+		if _, err := source.Fetch(CoinFilter{CoinType: []string{"BTC"}}); !LayoutChanged(err) {
+			t.Fatal("expect layout error: ", err)
+		}
+	}
+	if login.calls != 3 {
+		t.Fatal("layout errors should relogin once: ", login.calls)
+	}
+	now = now.Add(layoutRelogin)
+	source.Fetch(CoinFilter{CoinType: []string{"BTC"}})
+	if login.calls != 4 {
+		t.Fatal("layout relogin should be allowed after the window: ", login.calls)
+	}
+}
@@ -0,0 +1,68 @@
+package feixiaohao
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// layoutRelogin limit the relogins after a layout error, a page that really
+// changed would otherwise login on every fetch
+const layoutRelogin = maxLoginBackoff
+
+// Source read coin price from the feixiaohao userticker page of a logged in user
+type Source struct {
+	Session *Session
+
+	lock          sync.Mutex
+	layoutRetryAt time.Time
+}
+
+// NewSource create source with a login session
+func NewSource(session *Session) *Source {
+	return &Source{Session: session}
+}
+
+func (s *Source) Name() string {
+	return "feixiaohao"
+}
+
+// Fetch read the watchlist page, when the session turn out expired it login
+// again and retry once. A missing table may be a logged out page too, it
+// login again at most once per layoutRelogin
+func (s *Source) Fetch(filter CoinFilter) ([]CoinPriceMeta, error) {
+	cookies, err := s.Session.Cookies()
+	if err != nil {
+		return nil, err
+	}
+
+	metas, err := GetUserTicket(cookies, filter)
+	switch err.(type) {
+	case *SessionExpiredError:
+	case *LayoutError:
+		if !s.layoutRetry() {
+			return metas, err
+		}
+	default:
+		return metas, err
+	}
+
+	s.Session.Invalidate()
+	cookies, loginerr := s.Session.Cookies()
+	if loginerr != nil {
+		return nil, fmt.Errorf("%s, relogin: %s", err, loginerr)
+	}
+	return GetUserTicket(cookies, filter)
+}
+
+// layoutRetry tell if a layout error may login again, and start the window
+func (s *Source) layoutRetry() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.Session.now()
+	if now.Before(s.layoutRetryAt) {
+		return false
+	}
+	s.layoutRetryAt = now.Add(layoutRelogin)
+	return true
+}
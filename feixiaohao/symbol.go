@@ -0,0 +1,172 @@
+package feixiaohao
+
+import (
+	"strings"
+)
+
+// builtinAliases map the chinese names shown by the site to symbols
+var builtinAliases = map[string]string{
+	"比特币":   "BTC",
+	"以太坊":   "ETH",
+	"以太经典":  "ETC",
+	"莱特币":   "LTC",
+	"瑞波币":   "XRP",
+	"比特币现金": "BCH",
+	"柚子":    "EOS",
+	"狗狗币":   "DOGE",
+	"泰达币":   "USDT",
+	"艾达币":   "ADA",
+	"波场":    "TRX",
+	"恒星币":   "XLM",
+	"门罗币":   "XMR",
+	"达世币":   "DASH",
+	"小蚁":    "NEO",
+	"量子链":   "QTUM",
+}
+
+// NormalizeSymbol trim and upper case a symbol
+func NormalizeSymbol(symbol string) string {
+	return strings.ToUpper(strings.TrimSpace(symbol))
+}
+
+// HasSymbol tell if list hold symbol, compared after normalizing
+func HasSymbol(list []string, symbol string) bool {
+	symbol = NormalizeSymbol(symbol)
+	for _, value := range list {
+		if NormalizeSymbol(value) == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// Selector is one cointype entry, a symbol or an alias with an optional
+// @platform, like CMT, 比特币 or CMT@Binance
+type Selector struct {
+	// Entry is the entry as configured, the records it select carry it
+	// as CoinType
+	Entry    string
+	Symbol   string
+	Platform string
+}
+
+// SymbolTable resolve aliases to symbols, the config aliases override the
+// builtin ones
+type SymbolTable struct {
+	aliases map[string]string
+}
+
+func NewSymbolTable(aliases map[string]string) *SymbolTable {
+	table := &SymbolTable{aliases: make(map[string]string, len(builtinAliases)+len(aliases))}
+	for alias, symbol := range builtinAliases {
+		table.aliases[NormalizeSymbol(alias)] = symbol
+	}
+	for alias, symbol := range aliases {
+		table.aliases[NormalizeSymbol(alias)] = NormalizeSymbol(symbol)
+	}
+	return table
+}
+
+// Resolve return the symbol of name
+func (t *SymbolTable) Resolve(name string) string {
+	name = NormalizeSymbol(name)
+	if symbol, ok := t.aliases[name]; ok {
+		return symbol
+	}
+	return name
+}
+
+// Selector parse a cointype entry
+func (t *SymbolTable) Selector(entry string) Selector {
+	selector := Selector{Entry: entry}
+	name := entry
+	if at := strings.LastIndex(entry, "@"); at > 0 {
+		name, selector.Platform = entry[:at], NormalizeSymbol(entry[at+1:])
+	}
+	selector.Symbol = t.Resolve(name)
+	return selector
+}
+
+// CellSymbols return the symbols named by a scraped coin cell, the cell
+// may carry the chinese name next to the symbol like "BTC 比特币"
+func (t *SymbolTable) CellSymbols(cell string) []string {
+	fields := strings.FieldsFunc(cell, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == '/' || r == '(' || r == ')' || r == '（' || r == '）'
+	})
+	symbols := make([]string, 0, len(fields))
+	for _, field := range fields {
+		symbol := t.Resolve(field)
+		if !HasSymbol(symbols, symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+// Match tell if the row of cell on platform is selected
+func (t *SymbolTable) Match(selector Selector, cell, platform string) bool {
+	if selector.Platform != "" && NormalizeSymbol(platform) != selector.Platform {
+		return false
+	}
+	return HasSymbol(t.CellSymbols(cell), selector.Symbol)
+}
+
+// Symbols return the distinct symbols the entries of filter select, for the
+// sources that only know symbols
+func (f CoinFilter) Symbols() []string {
+	table := NewSymbolTable(f.Aliases)
+	symbols := make([]string, 0, len(f.CoinType))
+	for _, entry := range f.CoinType {
+		if symbol := table.Selector(entry).Symbol; !HasSymbol(symbols, symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+// Missing return a *NotWatchedError of source for the entries of filter
+// without record in metas, nil when every entry has one
+func Missing(source string, filter CoinFilter, metas []CoinPriceMeta) error {
+	var missing []string
+	for _, entry := range filter.CoinType {
+		found := false
+		for _, meta := range metas {
+			if meta.CoinType == entry || NormalizeSymbol(meta.CoinType) == NormalizeSymbol(entry) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, entry)
+		}
+	}
+	if len(missing) > 0 {
+		return &NotWatchedError{Coins: missing, Source: source}
+	}
+	return nil
+}
+
+// SelectRecords give every entry of filter the records of its symbol and
+// platform, aliases and @platform selectors are set as CoinType like the
+// userticker page do. The sources that only know symbols serve them so
+func SelectRecords(filter CoinFilter, records []CoinPriceMeta) []CoinPriceMeta {
+	table := NewSymbolTable(filter.Aliases)
+	metas := make([]CoinPriceMeta, 0, len(records))
+	for _, entry := range filter.CoinType {
+		selector := table.Selector(entry)
+		for _, meta := range records {
+			if NormalizeSymbol(meta.CoinType) != selector.Symbol {
+				continue
+			}
+			if selector.Platform != "" && NormalizeSymbol(meta.Platform) != selector.Platform {
+				continue
+			}
+			// plain symbols keep the normalized symbol of the source
+			if NormalizeSymbol(entry) != selector.Symbol {
+				meta.CoinType = entry
+			}
+			metas = append(metas, meta)
+		}
+	}
+	return metas
+}
@@ -0,0 +1,66 @@
+package feixiaohao
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestSymbolMatch(t *testing.T) {
+	symbols := NewSymbolTable(map[string]string{"大饼": "btc"})
+	cases := []struct {
+		entry    string
+		cell     string
+		platform string
+		match    bool
+	}{
+		{"ETH", "ETH", "Binance", true},
+		{"eth", " ETH 以太坊 ", "Binance", true},
+		{"ETH", "ETHOS", "Binance", false},
+		{"ETH", "BETH", "Binance", false},
+		{"ETH", "ETC", "Binance", false},
+		{"比特币", "BTC", "OKEx", true},
+		{"BTC", "比特币", "OKEx", true},
+		{"大饼", "BTC/比特币", "OKEx", true},
+		{"CMT@Binance", "CMT", "binance", true},
+		{"CMT@Binance", "CMT", "OKEx", false},
+	}
+	for _, c := range cases {
+		if got := symbols.Match(symbols.Selector(c.entry), c.cell, c.platform); got != c.match {
+			t.Errorf("%s on %s@%s: expect %v", c.entry, c.cell, c.platform, c.match)
+		}
+	}
+}
+
+func TestParseTicketTableSelectors(t *testing.T) {
+	page := `<table class="new-table new-table-custom" id="table">
+<thead><tr><th>币种</th><th>平台</th><th>价格</th><th>涨幅</th></tr></thead>
+<tbody>
+<tr><td>ETH</td><td>Binance</td><td>$2,000</td><td>1.0%</td></tr>
+<tr><td>ETHOS</td><td>Binance</td><td>$0.10</td><td>9.0%</td></tr>
+<tr><td>CMT</td><td>Binance</td><td>$0.15</td><td>2.0%</td></tr>
+<tr><td>CMT</td><td>OKEx</td><td>$0.16</td><td>3.0%</td></tr>
+</tbody></table>`
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metas, err := ParseTicketTable(document, CoinFilter{CoinType: []string{"以太坊", "CMT@OKEx"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metas) != 2 || metas[0].CoinType != "以太坊" || metas[0].Price != "$2,000" || metas[1].CoinType != "CMT@OKEx" || metas[1].Platform != "OKEx" {
+		t.Fatalf("bad selected records: %+v", metas)
+	}
+
+	metas, err = ParseTicketTable(document, CoinFilter{CoinType: []string{"CMT", "BETH"}})
+	// the rows of an ambiguous entry would alert under one name
+	if len(metas) != 0 || !Ambiguous(err) || !NotWatched(err) {
+		t.Fatal("expect CMT ambiguous without record and BETH missing: ", metas, err)
+	}
+	if !strings.Contains(err.Error(), "CMT@Binance, CMT@OKEx") {
+		t.Fatal("error should list the rows: ", err)
+	}
+}
@@ -1 +1,205 @@
 package feixiaohao
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/smileboywtu/CoinNotify/price"
+)
+
+// tableSelector find the rows of the userticker table
+const tableSelector = ".new-table.new-table-custom#table"
+
+// Column names of the userticker table
+const (
+	ColumnRank      = "rank"
+	ColumnCoin      = "coin"
+	ColumnPlatform  = "platform"
+	ColumnPrice     = "price"
+	ColumnVolume    = "volume"
+	ColumnMarketCap = "marketcap"
+	ColumnTurnover  = "turnover"
+	ColumnPercent   = "percent"
+	ColumnChange1h  = "change1h"
+	ColumnChange7d  = "change7d"
+)
+
+// requiredColumns can not be missing from the table
+var requiredColumns = []string{ColumnCoin, ColumnPlatform, ColumnPrice, ColumnPercent}
+
+// headerColumns map header keywords to columns, the first matching entry
+// win so the period changes come before the 24h change. Latin keywords
+// match whole words only, CHANGE is not found in EXCHANGE
+var headerColumns = []struct {
+	column   string
+	keywords []string
+}{
+	{ColumnChange1h, []string{"1H", "1小时"}},
+	{ColumnChange7d, []string{"7D", "7日", "7天", "周涨"}},
+	{ColumnPercent, []string{"涨幅", "涨跌", "CHANGE"}},
+	{ColumnTurnover, []string{"换手", "TURNOVER"}},
+	{ColumnMarketCap, []string{"市值", "MARKETCAP", "MARKET CAP"}},
+	{ColumnVolume, []string{"成交", "VOLUME"}},
+	{ColumnRank, []string{"排名", "序号", "RANK", "#"}},
+	{ColumnPlatform, []string{"平台", "交易所", "PLATFORM", "EXCHANGE"}},
+	{ColumnCoin, []string{"币种", "名称", "COIN", "NAME"}},
+	{ColumnPrice, []string{"价格", "最新价", "PRICE"}},
+}
+
+// HeaderColumn return the column of a header text, empty when unknown
+func HeaderColumn(header string) string {
+	header = strings.ToUpper(strings.TrimSpace(header))
+	for _, entry := range headerColumns {
+		for _, keyword := range entry.keywords {
+			if containsWord(header, keyword) {
+				return entry.column
+			}
+		}
+	}
+	return ""
+}
+
+// containsWord tell if keyword is in text and not part of a longer latin word
+func containsWord(text, keyword string) bool {
+	for start := 0; ; {
+		i := strings.Index(text[start:], keyword)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(keyword)
+		if !(i > 0 && isLetter(text[i-1]) && isLetter(keyword[0])) &&
+			!(end < len(text) && isLetter(text[end]) && isLetter(keyword[len(keyword)-1])) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+func isLetter(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+// ParseTicketTable read the watched coins from the userticker page, the
+// columns are located by their header text. The rows are selected by exact
+// symbol, see Selector, and an empty filter.CoinType read every coin of the
+// page. A page the parser does not know return a *LayoutError, the records
+// it could read come with *CellError for skipped rows, *NotWatchedError for
+// missing coins and *AmbiguousError for entries selecting several rows
+func ParseTicketTable(document *goquery.Document, filter CoinFilter) ([]CoinPriceMeta, error) {
+	table := document.Find(tableSelector)
+	if table.Length() == 0 {
+		return nil, &LayoutError{Reason: "userticker table not found"}
+	}
+
+	headers := make([]string, 0)
+	columns := make(map[string]int)
+	table.Find("thead th").Each(func(i int, selection *goquery.Selection) {
+		header := strings.TrimSpace(selection.Text())
+		headers = append(headers, header)
+		column := HeaderColumn(header)
+		if _, seen := columns[column]; column != "" && !seen {
+			columns[column] = i
+		}
+	})
+	if len(headers) == 0 {
+		return nil, &LayoutError{Reason: "userticker table has no header"}
+	}
+	for _, column := range requiredColumns {
+		if _, ok := columns[column]; !ok {
+			return nil, &LayoutError{Reason: fmt.Sprintf("no %s column in headers %s", column, strings.Join(headers, "|"))}
+		}
+	}
+
+	var result error
+	symbols := NewSymbolTable(filter.Aliases)
+	selectors := make([]Selector, 0, len(filter.CoinType))
+	for _, entry := range filter.CoinType {
+		selectors = append(selectors, symbols.Selector(entry))
+	}
+	// rows matched by every selector
+	matched := make([][]string, len(selectors))
+
+	metas := make([]CoinPriceMeta, 0, len(filter.CoinType))
+	table.Find("tbody>tr").Each(func(i int, selection *goquery.Selection) {
+		cells := selection.Find("td")
+		cell := func(column string) string {
+			index, ok := columns[column]
+			if !ok {
+				return ""
+			}
+			return strings.TrimSpace(cells.Eq(index).Text())
+		}
+
+		coin, platform := cell(ColumnCoin), cell(ColumnPlatform)
+		entries := make([]string, 0, 1)
+		if len(selectors) == 0 {
+			// every coin of the page, named by its symbol
+			if names := symbols.CellSymbols(coin); len(names) > 0 {
+				entries = append(entries, names[0])
+			}
+		}
+		for index, selector := range selectors {
+			if symbols.Match(selector, coin, platform) {
+				matched[index] = append(matched[index], coin+"@"+platform)
+				entries = append(entries, selector.Entry)
+			}
+		}
+		if len(entries) == 0 {
+			return
+		}
+
+		if _, err := price.Parse(cell(ColumnPrice)); err != nil {
+			result = multierror.Append(result, &CellError{Coin: coin, Column: ColumnPrice, Text: cell(ColumnPrice)})
+			return
+		}
+		if _, err := strconv.ParseFloat(strings.TrimSuffix(cell(ColumnPercent), "%"), 32); err != nil {
+			result = multierror.Append(result, &CellError{Coin: coin, Column: ColumnPercent, Text: cell(ColumnPercent)})
+			return
+		}
+
+		for _, entry := range entries {
+			metas = append(metas, CoinPriceMeta{
+				Platform:  platform,
+				Price:     cell(ColumnPrice),
+				Percent:   cell(ColumnPercent),
+				CoinType:  entry,
+				Volume:    cell(ColumnVolume),
+				Rank:      cell(ColumnRank),
+				MarketCap: cell(ColumnMarketCap),
+				Turnover:  cell(ColumnTurnover),
+				Change1h:  cell(ColumnChange1h),
+				Change7d:  cell(ColumnChange7d),
+			})
+		}
+	})
+
+	missing := make([]string, 0)
+	ambiguous := make(map[string]bool)
+	for index, selector := range selectors {
+		switch len(matched[index]) {
+		case 0:
+			missing = append(missing, selector.Entry)
+		case 1:
+		default:
+			ambiguous[selector.Entry] = true
+			result = multierror.Append(result, &AmbiguousError{Entry: selector.Entry, Rows: matched[index]})
+		}
+	}
+	if len(missing) > 0 {
+		result = multierror.Append(result, &NotWatchedError{Coins: missing})
+	}
+
+	// an ambiguous entry has no record, its rows would alert under one name
+	selected := metas[:0]
+	for _, meta := range metas {
+		if !ambiguous[meta.CoinType] {
+			selected = append(selected, meta)
+		}
+	}
+	return selected, result
+}
@@ -0,0 +1,147 @@
+package feixiaohao
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func loadTicketPage(t *testing.T, path string) *goquery.Document {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	document, err := goquery.NewDocumentFromReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return document
+}
+
+func TestParseTicketTable(t *testing.T) {
+	document := loadTicketPage(t, "testdata/userticker.html")
+
+	metas, err := ParseTicketTable(document, CoinFilter{CoinType: []string{"CMT", "BTC"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metas) != 2 {
+		t.Fatal("expect 2 coins: ", metas)
+	}
+
+	btc := metas[0]
+	want := CoinPriceMeta{
+		Platform:  "Binance",
+		Price:     "¥43,210.5",
+		Percent:   "+2.15%",
+		CoinType:  "BTC",
+		Volume:    "¥1.23亿",
+		Rank:      "1",
+		MarketCap: "¥8,432亿",
+		Turnover:  "1.46%",
+		Change1h:  "-0.12%",
+		Change7d:  "+8.40%",
+	}
+	if btc != want {
+		t.Fatalf("bad BTC record: %+v", btc)
+	}
+	if metas[1].CoinType != "CMT" || metas[1].Change7d != "-10.01%" {
+		t.Fatalf("bad CMT record: %+v", metas[1])
+	}
+
+	// empty cointype read the whole watchlist
+	metas, err = ParseTicketTable(document, CoinFilter{})
+	if err != nil || len(metas) != 3 || metas[2].CoinType != "IOST" {
+		t.Fatal("empty cointype should read every coin: ", metas, err)
+	}
+}
+
+func TestParseTicketTableReordered(t *testing.T) {
+	// move the percent column first, the parser should follow the headers
+	page := `<table class="new-table new-table-custom" id="table">
+<thead><tr><th>涨跌幅</th><th>币种</th><th>价格</th><th>平台</th></tr></thead>
+<tbody><tr><td>1.5%</td><td>CMT</td><td>$0.15</td><td>Bitfinex</td></tr></tbody>
+</table>`
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metas, err := ParseTicketTable(document, CoinFilter{CoinType: []string{"CMT"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metas) != 1 || metas[0].Percent != "1.5%" || metas[0].Platform != "Bitfinex" || metas[0].Price != "$0.15" {
+		t.Fatalf("bad reordered record: %+v", metas)
+	}
+}
+
+func TestHeaderColumn(t *testing.T) {
+	cases := map[string]string{
+		"24H涨幅":      ColumnPercent,
+		"1H涨幅":       ColumnChange1h,
+		"7日涨幅":       ColumnChange7d,
+		"流通市值":       ColumnMarketCap,
+		"24H成交额":     ColumnVolume,
+		"换手率":        ColumnTurnover,
+		"交易平台":       ColumnPlatform,
+		"Exchange":   ColumnPlatform,
+		"24h Change": ColumnPercent,
+		"1h %":       ColumnChange1h,
+		"备注":         "",
+	}
+	for header, column := range cases {
+		if got := HeaderColumn(header); got != column {
+			t.Errorf("header %s: expect %q got %q", header, column, got)
+		}
+	}
+}
+
+func TestParseTicketTableDrift(t *testing.T) {
+	parse := func(page string, coins ...string) ([]CoinPriceMeta, error) {
+		document, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ParseTicketTable(document, CoinFilter{CoinType: coins})
+	}
+
+	// table renamed
+	_, err := parse(`<table class="coin-table"><tbody><tr><td>CMT</td></tr></tbody></table>`, "CMT")
+	if _, ok := err.(*LayoutError); !ok {
+		t.Fatal("missing table should be a layout error: ", err)
+	}
+
+	// percent header renamed
+	_, err = parse(`<table class="new-table new-table-custom" id="table">
+<thead><tr><th>币种</th><th>平台</th><th>价格</th><th>24H</th></tr></thead></table>`, "CMT")
+	if !LayoutChanged(err) || NotWatched(err) {
+		t.Fatal("missing column should be a layout error: ", err)
+	}
+
+	// CMT not in the watchlist, IOST price unreadable
+	page := `<table class="new-table new-table-custom" id="table">
+<thead><tr><th>币种</th><th>平台</th><th>价格</th><th>涨幅</th></tr></thead>
+<tbody>
+<tr><td>BTC</td><td>Binance</td><td>¥43,210</td><td>1.2%</td></tr>
+<tr><td>IOST</td><td>Huobi</td><td>--</td><td>1.0%</td></tr>
+</tbody></table>`
+	metas, err := parse(page, "BTC", "CMT", "IOST")
+	if len(metas) != 1 || metas[0].CoinType != "BTC" {
+		t.Fatal("BTC should still be read: ", metas)
+	}
+	if !NotWatched(err) || !LayoutChanged(err) {
+		t.Fatal("expect missing coin and cell errors: ", err)
+	}
+	if !strings.Contains(err.Error(), "CMT") || !strings.Contains(err.Error(), "price of IOST") {
+		t.Fatal("error should name the coins: ", err)
+	}
+
+	if _, err := parse(page, "BTC"); err != nil {
+		t.Fatal("readable page should not fail: ", err)
+	}
+}
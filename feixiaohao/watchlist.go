@@ -0,0 +1,120 @@
+package feixiaohao
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/parnurzeal/gorequest"
+)
+
+// watchlistURL is the account watchlist api used by the site
+var watchlistURL = "https://api.feixiaohao.com/user/selfselect/"
+
+// WatchEntry is one coin of the account watchlist
+type WatchEntry struct {
+	// Code is the coin id of the site, like bitcoin
+	Code     string `json:"code"`
+	Symbol   string `json:"symbol"`
+	Platform string `json:"platform"`
+}
+
+type watchlistResponse struct {
+	Status  string          `json:"status"`
+	Code    string          `json:"code"`
+	Content json.RawMessage `json:"content"`
+}
+
+// Watchlist manage the 自选 list of the session user, it is the list the
+// userticker page show
+type Watchlist struct {
+	Session *Session
+}
+
+func NewWatchlist(session *Session) *Watchlist {
+	return &Watchlist{Session: session}
+}
+
+// List return the coins of the watchlist
+func (w *Watchlist) List() ([]WatchEntry, error) {
+	entries := make([]WatchEntry, 0)
+	if err := w.call("list", nil, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Add put symbol on platform into the watchlist, empty platform let the
+// site pick the default market
+func (w *Watchlist) Add(symbol, platform string) error {
+	return w.call("add", url.Values{"symbol": {symbol}, "platform": {platform}}, nil)
+}
+
+// Remove take symbol on platform out of the watchlist
+func (w *Watchlist) Remove(symbol, platform string) error {
+	return w.call("delete", url.Values{"symbol": {symbol}, "platform": {platform}}, nil)
+}
+
+// call run action with the session cookies, login again once when the
+// session turn out expired
+func (w *Watchlist) call(action string, form url.Values, out interface{}) error {
+	cookies, err := w.Session.Cookies()
+	if err != nil {
+		return err
+	}
+	err = watchlistCall(cookies, action, form, out)
+	if _, expired := err.(*SessionExpiredError); !expired {
+		return err
+	}
+
+	w.Session.Invalidate()
+	if cookies, err = w.Session.Cookies(); err != nil {
+		return err
+	}
+	return watchlistCall(cookies, action, form, out)
+}
+
+func watchlistCall(cookies []*http.Cookie, action string, form url.Values, out interface{}) error {
+	client := gorequest.New()
+	var request *gorequest.SuperAgent
+	if form == nil {
+		request = client.Get(watchlistURL + action)
+	} else {
+		request = client.Post(watchlistURL + action).Type("form").SendString(form.Encode())
+	}
+
+	response, body, errs := request.
+		AddCookies(cookies).
+		Timeout(10 * time.Second).
+		End()
+	if errs != nil {
+		return fmt.Errorf("watchlist %s error: %s", action, errs)
+	}
+	if response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden {
+		return &SessionExpiredError{Reason: response.Status}
+	}
+	if response.StatusCode != 200 {
+		return fmt.Errorf("watchlist %s error: %s", action, response.Status)
+	}
+
+	var result watchlistResponse
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		return fmt.Errorf("watchlist %s bad response: %s", action, err)
+	}
+	if result.Status != "success" {
+		var message string
+		json.Unmarshal(result.Content, &message)
+		if result.Code == "401" {
+			return &SessionExpiredError{Reason: message}
+		}
+		return fmt.Errorf("watchlist %s fails: %s", action, message)
+	}
+	if out != nil {
+		if err := json.Unmarshal(result.Content, out); err != nil {
+			return fmt.Errorf("watchlist %s bad content: %s", action, err)
+		}
+	}
+	return nil
+}
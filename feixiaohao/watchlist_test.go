@@ -0,0 +1,69 @@
+package feixiaohao
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWatchlist(t *testing.T) {
+	entries := []WatchEntry{{Code: "bitcoin", Symbol: "BTC", Platform: "binance"}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("token"); err != nil || cookie.Value == "t1" {
+			w.Write([]byte(`{"status":"fail","code":"401","content":"please login"}`))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/list":
+		case "/add":
+			entries = append(entries, WatchEntry{Symbol: r.FormValue("symbol"), Platform: r.FormValue("platform")})
+		case "/delete":
+			for i, entry := range entries {
+				if entry.Symbol == r.FormValue("symbol") {
+					entries = append(entries[:i], entries[i+1:]...)
+					break
+				}
+			}
+		default:
+			w.Write([]byte(`{"status":"fail","code":"404","content":"unknown action"}`))
+			return
+		}
+		content, _ := json.Marshal(entries)
+		json.NewEncoder(w).Encode(watchlistResponse{Status: "success", Content: content})
+	}))
+	defer server.Close()
+
+	defer func(url string) { watchlistURL = url }(watchlistURL)
+	watchlistURL = server.URL + "/"
+
+	now := time.Now()
+	login := &fakeLogin{}
+	session, dir := newTestSession(t, login, &now)
+	defer os.RemoveAll(dir)
+	watchlist := NewWatchlist(session)
+
+	// the first token is rejected, the list login again
+	list, err := watchlist.List()
+	if err != nil || len(list) != 1 || list[0].Symbol != "BTC" || login.calls != 2 {
+		t.Fatal("bad list: ", list, err, login.calls)
+	}
+
+	if err := watchlist.Add("CMT", "okex"); err != nil {
+		t.Fatal(err)
+	}
+	if err := watchlist.Remove("BTC", ""); err != nil {
+		t.Fatal(err)
+	}
+	list, err = watchlist.List()
+	if err != nil || len(list) != 1 || list[0].Symbol != "CMT" || list[0].Platform != "okex" {
+		t.Fatal("bad list after edit: ", list, err)
+	}
+
+	if err := watchlist.call("rename", nil, nil); err == nil {
+		t.Fatal("expect error of failed call")
+	}
+}
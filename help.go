@@ -3,13 +3,16 @@ package main
 var helpTemplate = `NAME:
    {{.Name}} - {{.Usage}}
 USAGE:
-   {{.Name}} [options]
+   {{.Name}} [options] [command [arguments...]]
 VERSION:
    {{.Version}}{{if or .Author .Email}}
 AUTHOR:{{if .Author}}
   {{.Author}}{{if .Email}} - <{{.Email}}>{{end}}{{else}}
   {{.Email}}{{end}}{{end}}
-OPTIONS:
+{{if .Commands}}COMMANDS:
+   {{range .Commands}}{{join .Names ", "}}{{ "\t" }}{{.Usage}}
+   {{end}}
+{{end}}OPTIONS:
    {{range .Flags}}{{.}}
    {{end}}
 `
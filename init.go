@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/smileboywtu/CoinNotify/aliyun"
+	"github.com/smileboywtu/CoinNotify/common"
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+	"github.com/smileboywtu/CoinNotify/notify"
+	"github.com/urfave/cli"
+	"github.com/yudai/gotty/pkg/homedir"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v2"
+)
+
+// initCommand ask the main settings and write them to the config file, the
+// current values are the defaults so it also edit an existing file
+func initCommand(load func(c *cli.Context) *AppConfigOpt) cli.Command {
+	return cli.Command{
+		Name:  "init",
+		Usage: "Create or update the config file interactively",
+		Action: func(c *cli.Context) {
+			configFile := rootContext(c).String("config")
+			if configFile == "" {
+				exit(fmt.Errorf("init need a config file path"), 1)
+			}
+			content, _ := ioutil.ReadFile(homedir.Expand(configFile))
+			if format := common.ConfigFormat(configFile, content); format != common.FormatYAML {
+				exit(fmt.Errorf("init only write yaml config files, %s is %s", configFile, format), 1)
+			}
+			config := load(c)
+
+			w := newWizard(os.Stdin, os.Stdout)
+			// do not echo the secrets typed in a terminal
+			if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+				w.password = func() (string, error) {
+					defer fmt.Fprintln(w.out)
+					password, err := term.ReadPassword(fd)
+					return string(password), err
+				}
+			}
+			keys, err := w.Run(config)
+			if err != nil {
+				exit(err, 1)
+			}
+
+			if config.UserName != "" && w.Confirm("Test the feixiaohao login", true) {
+				if _, err := feixiaohao.Login(feixiaohao.UserLoginMeta{
+					UserID:     config.UserName,
+					PassWD:     config.PassWD,
+					IsRemember: true,
+				}); err != nil {
+					fmt.Println("login failed: ", err)
+				} else {
+					fmt.Println("login ok")
+				}
+			}
+			if config.NotifyPhone != "" && w.Confirm("Send a test sms", false) {
+				if err := sendTestSMS(config); err != nil {
+					fmt.Println("sms failed: ", err)
+				} else {
+					fmt.Println("sms sent to " + config.NotifyPhone)
+				}
+			}
+			if w.err != nil {
+				exit(w.err, 1)
+			}
+
+			// only the asked keys are written, the values from env and flags
+			// stay out of the file
+			values, err := configValues(config, keys)
+			if err != nil {
+				exit(err, 1)
+			}
+			if err := common.SaveConfigFileYaml(configFile, values); err != nil {
+				exit(err, 1)
+			}
+			fmt.Println("config written to " + configFile)
+		},
+	}
+}
+
+// sendTestSMS send one alert through the aliyun settings of config
+func sendTestSMS(config *AppConfigOpt) error {
+	return aliyun.NewNotifier(aliyun.AliyunSMSOpt{
+		AccessKey:    config.AccessKey,
+		AccessID:     config.AccessID,
+		SignName:     config.SignName,
+		TemplateCode: config.TemplateCode,
+		NotifyPhone:  config.NotifyPhone,
+	}).Notify(notify.Alert{
+		CoinType: "BTC",
+		Platform: "CoinNotify",
+		Price:    "0",
+		Percent:  "0",
+	})
+}
+
+// configValues pick the yaml keys of config in the config field order
+func configValues(config *AppConfigOpt, keys []string) (yaml.MapSlice, error) {
+	content, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var all yaml.MapSlice
+	if err := yaml.Unmarshal(content, &all); err != nil {
+		return nil, err
+	}
+
+	values := yaml.MapSlice{}
+	for _, item := range all {
+		for _, key := range keys {
+			if item.Key == key {
+				values = append(values, item)
+				break
+			}
+		}
+	}
+	return values, nil
+}
+
+// clearAnswer is the answer that empty a value, an empty answer keep it
+const clearAnswer = "-"
+
+// wizard ask questions on a terminal, an empty answer keep the current
+// value and clearAnswer empty it. The first read error stop the questions
+// and is kept in err
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+	err error
+	// password read a secret without echo, nil to read secrets from in
+	password func() (string, error)
+}
+
+func newWizard(in io.Reader, out io.Writer) *wizard {
+	return &wizard{in: bufio.NewReader(in), out: out}
+}
+
+// Run ask the settings a new config need and set them on config, it return
+// the yaml keys asked
+func (w *wizard) Run(config *AppConfigOpt) ([]string, error) {
+	var keys []string
+	text := func(key, label string, value *string, secret bool, check func(string) error) {
+		keys = append(keys, key)
+		*value = w.Ask(label, *value, secret, check)
+	}
+
+	fmt.Fprintln(w.out, "## feixiaohao account")
+	text("userid", "User id", &config.UserName, false, nil)
+	text("passwd", "Password", &config.PassWD, true, nil)
+
+	fmt.Fprintln(w.out, "## aliyun sms")
+	text("accesskey", "AccessKey", &config.AccessKey, true, nil)
+	text("accessid", "AccessID", &config.AccessID, false, nil)
+	text("signname", "Sign name", &config.SignName, false, nil)
+	text("templatecode", "Template code", &config.TemplateCode, false, nil)
+	text("notifyphone", "Phone, comma separated, "+clearAnswer+" for none", &config.NotifyPhone, false, checkPhones)
+
+	fmt.Fprintln(w.out, "## coins")
+	coins := strings.Join(config.CoinTypes.Symbols(), ",")
+	text("cointype", "Coins, comma separated, "+clearAnswer+" to watch the whole watchlist", &coins, false, nil)
+	config.CoinTypes = keepSpecs(config.CoinTypes, coins)
+
+	keys = append(keys, "lowpricepercent", "highpricepercent", "amplitude", "notifytimeperiod")
+	config.PriceLowPercent = w.AskFloat("Low percent", config.PriceLowPercent, nil)
+	config.PriceHighPercent = w.AskFloat("High percent", config.PriceHighPercent, func(high float32) error {
+		if high <= config.PriceLowPercent {
+			return fmt.Errorf("should be above the low percent %v", config.PriceLowPercent)
+		}
+		return nil
+	})
+	config.PriceAmplitude = w.AskFloat("Amplitude", config.PriceAmplitude, func(amplitude float32) error {
+		if amplitude <= 0 {
+			return fmt.Errorf("should be above 0")
+		}
+		return nil
+	})
+	config.NotifyTimePeriod = w.AskInt("Notify time period in seconds", config.NotifyTimePeriod)
+
+	return keys, w.err
+}
+
+// Ask read one answer, check may reject it and ask again
+func (w *wizard) Ask(label, current string, secret bool, check func(string) error) string {
+	for w.err == nil {
+		shown := current
+		if secret && current != "" {
+			shown = redacted
+		}
+		if shown != "" {
+			fmt.Fprintf(w.out, "%s [%s]: ", label, shown)
+		} else {
+			fmt.Fprintf(w.out, "%s: ", label)
+		}
+
+		var line string
+		var err error
+		if secret && w.password != nil {
+			line, err = w.password()
+		} else {
+			line, err = w.in.ReadString('\n')
+		}
+		if err != nil && (err != io.EOF || line == "") {
+			w.err = fmt.Errorf("init aborted: %s", err)
+			break
+		}
+		answer := strings.TrimSpace(line)
+		switch answer {
+		case "":
+			answer = current
+		case clearAnswer:
+			answer = ""
+		}
+		if check != nil {
+			if err := check(answer); err != nil {
+				fmt.Fprintln(w.out, "  "+err.Error())
+				continue
+			}
+		}
+		return answer
+	}
+	return current
+}
+
+// AskFloat read a number
+func (w *wizard) AskFloat(label string, current float32, check func(float32) error) float32 {
+	var value float32
+	w.Ask(label, strconv.FormatFloat(float64(current), 'f', -1, 32), false, func(answer string) error {
+		number, err := strconv.ParseFloat(answer, 32)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", answer)
+		}
+		value = float32(number)
+		if check != nil {
+			return check(value)
+		}
+		return nil
+	})
+	if w.err != nil {
+		return current
+	}
+	return value
+}
+
+// AskInt read a whole number
+func (w *wizard) AskInt(label string, current int64) int64 {
+	var value int64
+	w.Ask(label, strconv.FormatInt(current, 10), false, func(answer string) error {
+		number, err := strconv.ParseInt(answer, 10, 64)
+		if err != nil || number < 0 {
+			return fmt.Errorf("%q is not a whole number", answer)
+		}
+		value = number
+		return nil
+	})
+	if w.err != nil {
+		return current
+	}
+	return value
+}
+
+// Confirm ask a yes or no question
+func (w *wizard) Confirm(label string, yes bool) bool {
+	current := "n"
+	if yes {
+		current = "y"
+	}
+	answer := w.Ask(label+" (y/n)", current, false, func(answer string) error {
+		switch strings.ToLower(answer) {
+		case "y", "yes", "n", "no":
+			return nil
+		}
+		return fmt.Errorf("answer y or n")
+	})
+	return w.err == nil && strings.HasPrefix(strings.ToLower(answer), "y")
+}
+
+// checkPhones accept an empty answer or a list of phone numbers
+func checkPhones(phones string) error {
+	if phones == "" {
+		return nil
+	}
+	for _, phone := range strings.Split(phones, ",") {
+		if !phonePattern.MatchString(strings.TrimSpace(phone)) {
+			return fmt.Errorf("%q is not a phone number", phone)
+		}
+	}
+	return nil
+}
+
+// keepSpecs build the coin list of the answer, the coins already in list
+// keep their own settings
+func keepSpecs(list feixiaohao.CoinList, answer string) feixiaohao.CoinList {
+	specs := list.Specs()
+	coins := feixiaohao.CoinList{}
+	for _, symbol := range strings.Split(answer, ",") {
+		symbol = strings.TrimSpace(symbol)
+		if symbol == "" {
+			continue
+		}
+		spec, ok := specs[symbol]
+		if !ok {
+			spec = feixiaohao.CoinSpec{Symbol: symbol}
+		}
+		coins = append(coins, spec)
+	}
+	return coins
+}
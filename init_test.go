@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/smileboywtu/CoinNotify/common"
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+)
+
+func TestWizardRun(t *testing.T) {
+	config := &AppConfigOpt{}
+	if err := common.ApplyDefaultValues(config); err != nil {
+		t.Fatal(err)
+	}
+	high := float32(8)
+	config.PassWD = "old"
+	config.CoinTypes = feixiaohao.CoinList{{Symbol: "ETH", High: &high}}
+
+	answers := []string{
+		"bob", "", // feixiaohao, keep the password
+		"key", "id", "sign", "SMS_1", "138-0000", "13800000000", // bad phone asked again
+		"BTC, ETH",
+		"-3", "-4", "5", "abc", "0.5", "", // high below low and a bad amplitude asked again
+	}
+	var out bytes.Buffer
+	w := newWizard(strings.NewReader(strings.Join(answers, "\n")+"\n"), &out)
+	keys, err := w.Run(config)
+	if err != nil {
+		t.Fatal(err, out.String())
+	}
+
+	if config.UserName != "bob" || config.PassWD != "old" || config.NotifyPhone != "13800000000" {
+		t.Fatalf("bad account %+v", config)
+	}
+	if config.PriceLowPercent != -3 || config.PriceHighPercent != 5 || config.PriceAmplitude != 0.5 || config.NotifyTimePeriod != 3600 {
+		t.Fatalf("bad thresholds %+v", config)
+	}
+	if !reflect.DeepEqual(config.CoinTypes.Symbols(), []string{"BTC", "ETH"}) || config.CoinTypes[1].High != &high {
+		t.Fatalf("bad coins %+v", config.CoinTypes)
+	}
+	if strings.Contains(out.String(), "old") || !strings.Contains(out.String(), "is not a phone number") {
+		t.Fatal("bad prompts: ", out.String())
+	}
+
+	values, err := configValues(config, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != len(keys) || values[0].Key != "accesskey" {
+		t.Fatalf("bad values %v", values)
+	}
+
+	// - clear a value, the whole watchlist is watched again
+	answers = []string{"", "", "", "", "", "", "-", "-", "", "", "", ""}
+	w = newWizard(strings.NewReader(strings.Join(answers, "\n")+"\n"), &out)
+	if _, err := w.Run(config); err != nil {
+		t.Fatal(err, out.String())
+	}
+	if len(config.CoinTypes) != 0 || config.NotifyPhone != "" || config.UserName != "bob" {
+		t.Fatalf("- should clear the coins and the phones %+v", config)
+	}
+
+	// input ending early abort the wizard
+	w = newWizard(strings.NewReader("bob\n"), &out)
+	if _, err := w.Run(config); err == nil {
+		t.Fatal("short input should abort")
+	}
+
+	// a terminal read the secrets without echo
+	w = newWizard(strings.NewReader("carol\n"), &out)
+	w.password = func() (string, error) {
+		return "hunter2", nil
+	}
+	if w.Ask("User id", "", false, nil) != "carol" || w.Ask("Password", "old", true, nil) != "hunter2" {
+		t.Fatal("secrets should be read from the terminal")
+	}
+}
@@ -5,41 +5,232 @@ import (
 	"os"
 	"fmt"
 	"time"
-	"math"
 	"strings"
 	"strconv"
+	"sort"
+	"sync"
 	"syscall"
-	"net/http"
 	"os/signal"
 
 	"github.com/urfave/cli"
+	"github.com/hashicorp/go-multierror"
 	"github.com/yudai/gotty/pkg/homedir"
-	"github.com/smileboywtu/CoinNotify/aliyun"
 	"github.com/smileboywtu/CoinNotify/common"
 	"github.com/smileboywtu/CoinNotify/feixiaohao"
+	"github.com/smileboywtu/CoinNotify/notify"
+	"github.com/smileboywtu/CoinNotify/price"
+	"github.com/smileboywtu/CoinNotify/rule"
+	"github.com/smileboywtu/CoinNotify/source"
+	"github.com/smileboywtu/CoinNotify/store"
 )
 
 type TaskContext struct {
-	LastNotifyTime map[string]int64
-	LastRecord     map[string]float32
+	LastNotifyTime  map[string]int64
+	LastRecord      map[string]float32
+	MuteUntil       map[string]int64
+
+	// LastAlertRecord is the percent when the coin notified last time
+	LastAlertRecord map[string]float32
+	LastPrice       map[string]price.Value
+	// LastRuleNotify is the last alert time of each rule by coin and Rule.Key
+	LastRuleNotify  map[string]map[string]int64
+
+	Source          source.PriceSource
+	Filter          feixiaohao.CoinFilter
+	// Rules decide when to notify, empty means the defaults built from Filter
+	Rules           []rule.Rule
+
+	// Notifiers is the default channels, Registry resolve the rule channels
+	Notifiers       []notify.Notifier
+	Registry        *notify.Registry
+
+	// StateFile keep the maps across restarts, empty to disable
+	StateFile       string
+
+	// FetchFailures count the failed rounds in a row, a warning is sent
+	// when it reach FailureWarn
+	FetchFailures   int
+	FailureWarn     int
+
+	// lock guard the maps against the chat command goroutine
+	lock            *sync.Mutex
+}
+
+func NewTaskContext() *TaskContext {
+	return &TaskContext{
+		LastNotifyTime:  make(map[string]int64),
+		LastRecord:      make(map[string]float32),
+		MuteUntil:       make(map[string]int64),
+		LastAlertRecord: make(map[string]float32),
+		LastPrice:       make(map[string]price.Value),
+		LastRuleNotify:  make(map[string]map[string]int64),
+		lock:            &sync.Mutex{},
+	}
+}
+
+// Restore replace the notify state with snapshot
+func (ctx *TaskContext) Restore(snapshot *store.Snapshot) {
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+	ctx.LastNotifyTime = snapshot.LastNotifyTime
+	ctx.LastRecord = snapshot.LastRecord
+	ctx.LastAlertRecord = snapshot.LastAlertRecord
+	ctx.LastPrice = snapshot.LastPrice
+	ctx.MuteUntil = snapshot.MuteUntil
+	ctx.LastRuleNotify = snapshot.LastRuleNotify
+}
+
+// saveState write the notify state to StateFile, the caller hold the lock
+func (ctx *TaskContext) saveState() error {
+	if ctx.StateFile == "" {
+		return nil
+	}
+	return store.Save(ctx.StateFile, &store.Snapshot{
+		LastNotifyTime:  ctx.LastNotifyTime,
+		LastRecord:      ctx.LastRecord,
+		LastAlertRecord: ctx.LastAlertRecord,
+		LastPrice:       ctx.LastPrice,
+		MuteUntil:       ctx.MuteUntil,
+		LastRuleNotify:  ctx.LastRuleNotify,
+	})
+}
+
+// LastPercent return the last percent seen for coin, it implement telegram.Controller
+func (ctx *TaskContext) LastPercent(coin string) (float32, bool) {
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+	percent, ok := ctx.LastRecord[coin]
+	return percent, ok
+}
+
+// Mute silence coin until the given time, zero time unmute it
+func (ctx *TaskContext) Mute(coin string, until time.Time) {
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+	if until.IsZero() {
+		delete(ctx.MuteUntil, coin)
+	} else {
+		ctx.MuteUntil[coin] = until.Unix()
+	}
+	if err := ctx.saveState(); err != nil {
+		fmt.Printf("save state error: %s\n", err)
+	}
+}
+
+// watched return the watched coins, with an empty CoinType every coin of
+// the watchlist is watched and the coins seen so far are returned
+func (ctx *TaskContext) watched() []string {
+	if len(ctx.Filter.CoinType) > 0 {
+		return ctx.Filter.CoinType
+	}
+	coins := make([]string, 0, len(ctx.LastRecord))
+	for coin := range ctx.LastRecord {
+		coins = append(coins, coin)
+	}
+	sort.Strings(coins)
+	return coins
+}
+
+// watching tell if the records of coin are still wanted, a stream keep the
+// coins it was started with after a reload drop some. The caller hold the lock
+func (ctx *TaskContext) watching(coin string) bool {
+	if len(ctx.Filter.CoinType) == 0 {
+		return true
+	}
+	for _, entry := range ctx.Filter.CoinType {
+		if entry == coin || feixiaohao.NormalizeSymbol(entry) == feixiaohao.NormalizeSymbol(coin) {
+			return true
+		}
+	}
+	return false
+}
+
+// notifiers return the default notifiers, a reload may swap them
+func (ctx *TaskContext) notifiers() []notify.Notifier {
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+	return ctx.Notifiers
+}
+
+// Status describe the watched coins
+func (ctx *TaskContext) Status() string {
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+
+	lines := []string{fmt.Sprintf("source: %s", ctx.Source.Name())}
+	now := time.Now().Unix()
+	for _, coin := range ctx.watched() {
+		line := coin
+		if percent, ok := ctx.LastRecord[coin]; ok {
+			line += fmt.Sprintf(" %.2f%%", percent)
+		} else {
+			line += " no record"
+		}
+		if last := ctx.LastNotifyTime[coin]; last > 0 {
+			line += ", notified " + time.Unix(last, 0).Format("01-02 15:04")
+		}
+		if until := ctx.MuteUntil[coin]; until > now {
+			line += ", muted until " + time.Unix(until, 0).Format("01-02 15:04")
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// recordFetch count the failed fetch rounds and return the warning to send
+// when the count reach FailureWarn, and the notice when a warned source
+// recover
+func (ctx *TaskContext) recordFetch(err error) *notify.Alert {
+	// missing and ambiguous coins still come with the other records
+	if err == nil || feixiaohao.Partial(err) {
+		warned := ctx.FailureWarn > 0 && ctx.FetchFailures >= ctx.FailureWarn
+		ctx.FetchFailures = 0
+		if !warned {
+			return nil
+		}
+		return &notify.Alert{
+			Kind:     notify.KindWarning,
+			CoinType: strings.Join(ctx.watched(), ","),
+			Platform: ctx.Source.Name(),
+			Reason:   "price source recovered",
+		}
+	}
+
+	ctx.FetchFailures++
+	if ctx.FailureWarn <= 0 || ctx.FetchFailures != ctx.FailureWarn {
+		return nil
+	}
 
-	Cookies        []*http.Cookie
-	Filter         feixiaohao.CoinFilter
-	AliyunCtx      aliyun.AliyunSMSOpt
+	reason := "price source failing"
+	if feixiaohao.LayoutChanged(err) {
+		reason = "feixiaohao page layout changed"
+	}
+	return &notify.Alert{
+		Kind:     notify.KindWarning,
+		CoinType: strings.Join(ctx.watched(), ","),
+		Platform: ctx.Source.Name(),
+		Reason:   fmt.Sprintf("%s, %d rounds in a row: %s", reason, ctx.FetchFailures, err),
+	}
 }
 
-// RenewCookies renew feixiaohao cookies
-func RenewCookies(cookies []*http.Cookie, meta feixiaohao.UserLoginMeta) chan struct{} {
+// RenewSession login again shortly before the feixiaohao session expire,
+// Task share the session and login on demand when it expire earlier
+func RenewSession(session *feixiaohao.Session) chan struct{} {
 
-	// one day renew
-	ticker := time.NewTicker(3600 * 12 * time.Second)
+	ticker := time.NewTicker(time.Minute)
 	quit := make(chan struct{})
 	go func() {
 		for {
 			select {
 			case <-ticker.C:
-				cookie, _ := feixiaohao.Login(meta)
-				copy(cookies, cookie)
+				// logged out sessions login on the next fetch
+				expires := session.Expires()
+				if expires.IsZero() || time.Until(expires) > 10*time.Minute {
+					continue
+				}
+				if err := session.Renew(); err != nil {
+					fmt.Printf("renew session error: %s\n", err)
+				}
 			case <-quit:
 				ticker.Stop()
 				return
@@ -49,60 +240,213 @@ func RenewCookies(cookies []*http.Cookie, meta feixiaohao.UserLoginMeta) chan st
 	return quit
 }
 
+// reportFetch record a fetch round, or a stream connection, and send the
+// warning it bring
+func reportFetch(ctx *TaskContext, err error, errc chan error) {
+	if warning := ctx.recordFetch(err); warning != nil {
+		notifiers := ctx.notifiers()
+		go func() {
+			if err := notify.Dispatch(notifiers, *warning); err != nil {
+				errc <- err
+			}
+		}()
+	}
+}
+
 func Task(ctx *TaskContext, errc chan error) {
-	pricemeta, err := feixiaohao.GetUserTicket(ctx.Cookies, ctx.Filter)
+	pricemeta, err := ctx.Source.Fetch(ctx.Filter)
+	reportFetch(ctx, err, errc)
 	if err != nil {
+		err = fmt.Errorf("%s: %s", ctx.Source.Name(), err)
 		go func() {
 			errc <- err
 		}()
 	}
 
+	Process(ctx, pricemeta, errc)
+}
+
+// Process check the records against the rules and send the alerts, it is
+// called with the polled records of one round or with one streamed record
+func Process(ctx *TaskContext, pricemeta []feixiaohao.CoinPriceMeta, errc chan error) {
+	// alerts by channel name, the empty name is the default notifiers
+	routed := make(map[string][]notify.Alert)
+
+	ctx.lock.Lock()
+	changed := false
 	for _, meta := range pricemeta {
+		if !ctx.watching(meta.CoinType) {
+			continue
+		}
+
+		needed, percentf, matched := checkNotify(meta, *ctx)
+		if needed {
+			reasons := make([]string, 0, len(matched))
+			channels := make(map[string]bool)
+			for _, r := range matched {
+				reasons = append(reasons, r.Reason())
+				if len(r.Channels) == 0 {
+					channels[""] = true
+				}
+				for _, channel := range r.Channels {
+					channels[channel] = true
+				}
+			}
 
-		notify, percentf := NeedNotify(meta, *ctx)
-		if notify {
-			errs := aliyun.SendSMS(ctx.AliyunCtx, aliyun.SMSContentCtx{
-				meta.Platform,
-				meta.CoinType,
-				meta.Price,
-				meta.Percent,
-			})
-			if errs != nil {
-				go func() {
-					errc <- err
-				}()
+			platform := meta.Platform
+			if meta.Sources != "" {
+				platform += "(" + meta.Sources + ")"
+			}
+			alert := notify.Alert{
+				CoinType: meta.CoinType,
+				Platform: platform,
+				Price:    meta.Price,
+				Percent:  meta.Percent,
+				Reason:   strings.Join(reasons, "; "),
 			}
-			ctx.LastNotifyTime[meta.CoinType] = time.Now().Unix()
+			for channel := range channels {
+				routed[channel] = append(routed[channel], alert)
+			}
+			now := time.Now().Unix()
+			ctx.LastNotifyTime[meta.CoinType] = now
+			ctx.LastAlertRecord[meta.CoinType] = percentf
+			if ctx.LastRuleNotify[meta.CoinType] == nil {
+				ctx.LastRuleNotify[meta.CoinType] = make(map[string]int64)
+			}
+			for _, r := range matched {
+				ctx.LastRuleNotify[meta.CoinType][r.Key()] = now
+			}
+			changed = true
+		}
+
+		if last, ok := ctx.LastRecord[meta.CoinType]; !ok || last != percentf {
+			ctx.LastRecord[meta.CoinType] = percentf
+			changed = true
 		}
+		if value, err := price.Parse(meta.Price); err == nil && value != ctx.LastPrice[meta.CoinType] {
+			ctx.LastPrice[meta.CoinType] = value
+			changed = true
+		}
+	}
+	if changed {
+		if err := ctx.saveState(); err != nil {
+			go func() {
+				errc <- err
+			}()
+		}
+	}
+	ctx.lock.Unlock()
 
-		ctx.LastRecord[meta.CoinType] = percentf
+	// send all the alerts of this round together so digest channels
+	// like email send one message
+	if errs := dispatch(ctx, routed); errs != nil {
+		go func() {
+			errc <- errs
+		}()
+	}
+}
+
+// dispatch send the routed alerts to their channels
+func dispatch(ctx *TaskContext, routed map[string][]notify.Alert) error {
+	var result error
+	// a reload may swap the notifiers while the alerts are sent
+	ctx.lock.Lock()
+	defaults, registry := ctx.Notifiers, ctx.Registry
+	ctx.lock.Unlock()
+	for channel, alerts := range routed {
+		notifiers := defaults
+		if channel != "" {
+			notifier, ok := registry.Get(channel)
+			if !ok {
+				result = multierror.Append(result, fmt.Errorf("unknown notify channel: %s", channel))
+				continue
+			}
+			notifiers = []notify.Notifier{notifier}
+		}
+		if err := notify.DispatchBatch(notifiers, alerts); err != nil {
+			result = multierror.Append(result, err)
+		}
 	}
+	return result
 }
 
 func NeedNotify(meta feixiaohao.CoinPriceMeta, ctx TaskContext) (bool, float32) {
+	needed, percentf, _ := checkNotify(meta, ctx)
+	return needed, percentf
+}
+
+// checkNotify works like NeedNotify and also return the rules that fired
+func checkNotify(meta feixiaohao.CoinPriceMeta, ctx TaskContext) (bool, float32, []rule.Rule) {
 
 	percentf, errs := ConvertPercent2Float(meta.Percent)
 	if errs != nil {
-		return false, 0.0
+		return false, 0.0, nil
+	}
+
+	now := time.Now().Unix()
+
+	// muted by chat command
+	if now < ctx.MuteUntil[meta.CoinType] {
+		return false, percentf, nil
 	}
 
-	if ctx.LastNotifyTime[meta.CoinType] == 0 {
-		return true, percentf
+	state := rule.State{
+		Now:          now,
+		Percent:      percentf,
+		LastPercent:  ctx.LastRecord[meta.CoinType],
+		AlertPercent: ctx.LastAlertRecord[meta.CoinType],
+		LastNotify:   ctx.LastNotifyTime[meta.CoinType],
+		RuleNotify:   ctx.LastRuleNotify[meta.CoinType],
 	}
+	if value, err := price.Parse(meta.Price); err == nil {
+		state.Price, state.Currency, state.HasPrice = value.Amount, value.Currency, true
 
-	if float32(percentf) >= ctx.Filter.High || float32(percentf) <= ctx.Filter.Low {
-		// time limit
-		if ctx.LastNotifyTime[meta.CoinType] > 0 && time.Now().Unix()-ctx.LastNotifyTime[meta.CoinType] >= ctx.Filter.TimePeriod {
-			return true, percentf
+		// a price in another currency can not tell a crossing
+		if last, ok := ctx.LastPrice[meta.CoinType]; ok && last.Comparable(value) {
+			state.LastPrice, state.HasLastPrice = last.Amount, true
 		}
 	}
+	if value, err := price.Parse(meta.Volume); err == nil {
+		state.Volume = &value
+	}
+	if value, err := price.Parse(meta.MarketCap); err == nil {
+		state.MarketCap = &value
+	}
+
+	rules := ctx.Rules
+	if len(rules) == 0 {
+		threshold := ctx.Filter.ThresholdOf(meta.CoinType)
+		rules = rule.Defaults(threshold.High, threshold.Low, threshold.Amplitude, threshold.TimePeriod)
+		rules = append(rules, rule.PriceLevels(threshold.Above, threshold.Below)...)
+	} else {
+		rules = append(rules[:len(rules):len(rules)], SpecRules(ctx.Filter, meta.CoinType)...)
+	}
 
-	// amplitude
-	if math.Abs(float64(ctx.LastRecord[meta.CoinType]-percentf)) >= float64(ctx.Filter.Amplitude) {
-		return true, percentf
+	matched := rule.Evaluate(rules, meta.CoinType, state)
+	return len(matched) > 0, percentf, matched
+}
+
+// SpecRules build the rules of the thresholds set on the coin itself, they
+// apply on top of the configured rules. notifytimeperiod is the cooldown
+// of these rules only
+func SpecRules(filter feixiaohao.CoinFilter, coin string) []rule.Rule {
+	spec, ok := filter.Specs[coin]
+	if !ok {
+		return nil
 	}
 
-	return false, percentf
+	threshold := filter.ThresholdOf(coin)
+	var rules []rule.Rule
+	if spec.High != nil {
+		rules = append(rules, rule.Rule{Condition: rule.PercentAbove, Value: float64(threshold.High), Cooldown: threshold.TimePeriod})
+	}
+	if spec.Low != nil {
+		rules = append(rules, rule.Rule{Condition: rule.PercentBelow, Value: float64(threshold.Low), Cooldown: threshold.TimePeriod})
+	}
+	if spec.Amplitude != nil {
+		rules = append(rules, rule.Rule{Condition: rule.Change, Value: float64(threshold.Amplitude)})
+	}
+	return append(rules, rule.PriceLevels(threshold.Above, threshold.Below)...)
 }
 
 func ConvertPercent2Float(percent string) (float32, error) {
@@ -119,43 +463,75 @@ func ConvertPercent2Float(percent string) (float32, error) {
 	return float32(percentf), nil
 }
 
-func Start(config *AppConfigOpt) {
+// NewCoinFilter build the coin filter of config
+func NewCoinFilter(config *AppConfigOpt) feixiaohao.CoinFilter {
+	return feixiaohao.CoinFilter{
+		CoinType:   config.CoinTypes.Symbols(),
+		High:       config.PriceHighPercent,
+		Low:        config.PriceLowPercent,
 
-	loginmeta := feixiaohao.UserLoginMeta{
-		UserID:     config.UserName,
-		PassWD:     config.PassWD,
-		IsRemember: false,
+		Amplitude:  config.PriceAmplitude,
+		TimePeriod: config.NotifyTimePeriod,
+		Specs:      config.CoinTypes.Specs(),
+		Aliases:    config.Aliases,
 	}
-	cookies, err := feixiaohao.Login(loginmeta)
+}
+
+// Start run the notifier, reloader re-read the config on SIGHUP or when
+// its file change and may be nil
+func Start(config *AppConfigOpt, reloader *ConfigReloader) {
+
+	pricesource, quit, err := NewPriceSource(config)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	// start renew task
-	quit := RenewCookies(cookies, loginmeta)
+	settings, err := NewReload(config)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-	aliopts := aliyun.AliyunSMSOpt{
-		AccessKey:    config.AccessKey,
-		AccessID:     config.AccessID,
-		SignName:     config.SignName,
-		TemplateCode: config.TemplateCode,
-		NotifyPhone:  config.NotifyPhone,
+	filter := settings.Filter
+	taskctx := NewTaskContext()
+	taskctx.Source = pricesource
+	taskctx.Filter = filter
+	taskctx.Rules = settings.Rules
+	taskctx.Notifiers = settings.Notifiers
+	taskctx.Registry = settings.Registry
+	taskctx.FailureWarn = config.FailureWarn
+
+	// restore the state of the coins still watched
+	if config.StateFile != "" {
+		statefile := homedir.Expand(config.StateFile)
+		snapshot, err := store.Load(statefile)
+		if err != nil {
+			if _, corrupt := err.(*store.CorruptError); !corrupt {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			fmt.Println(err)
+		}
+		snapshot.Retain(func(coin string) bool {
+			return len(filter.CoinType) == 0 || feixiaohao.HasSymbol(filter.CoinType, coin)
+		})
+		taskctx.Restore(snapshot)
+		taskctx.StateFile = statefile
 	}
-	filter := feixiaohao.CoinFilter{
-		CoinType:   config.CoinTypes,
-		High:       config.PriceHighPercent,
-		Low:        config.PriceLowPercent,
 
-		Amplitude:  config.PriceAmplitude,
-		TimePeriod: config.NotifyTimePeriod,
+	errc := make(chan error, 2)
+
+	// report the source switches
+	if failover, ok := pricesource.(*source.Failover); ok {
+		failover.OnSwitch = SourceSwitchReporter(taskctx, config.FailoverNotify, errc)
 	}
-	taskctx := &TaskContext{
-		LastNotifyTime: make(map[string]int64),
-		LastRecord:     make(map[string]float32),
-		Cookies:        cookies,
-		Filter:         filter,
-		AliyunCtx:      aliopts,
+
+	// chat commands
+	botquit := make(chan struct{})
+	if config.TelegramToken != "" {
+		bot := NewTelegramBot(config)
+		go bot.Run(taskctx, botquit, errc)
 	}
 
 	// define quit signal
@@ -165,18 +541,56 @@ func Start(config *AppConfigOpt) {
 	exit := make(chan struct{})
 	go func() {
 		<-sigs
-		quit <- struct{}{}
+		if quit != nil {
+			quit <- struct{}{}
+		}
+		close(botquit)
 		exit <- struct{}{}
 	}()
 
-	errc := make(chan error, 2)
-	timer := time.NewTicker(2 * time.Second)
+	// reload on SIGHUP or config file change
+	reloads := make(chan struct{}, 1)
+	hups := make(chan os.Signal, 1)
+	reloadquit := make(chan struct{})
+	defer close(reloadquit)
+	if reloader != nil {
+		signal.Notify(hups, syscall.SIGHUP)
+		if reloader.File != "" {
+			go WatchConfigFile(reloader.File, 5*time.Second, reloads, reloadquit)
+		}
+	}
+
+	// streaming sources push records, the others are polled
+	var tick <-chan time.Time
+	updates := make(chan feixiaohao.CoinPriceMeta, 16)
+	streamquit := make(chan struct{})
+	defer close(streamquit)
+	if stream, ok := pricesource.(source.StreamSource); ok {
+		go stream.Stream(filter, updates, streamquit, errc)
+	} else {
+		timer := time.NewTicker(PollInterval(config))
+		defer timer.Stop()
+		tick = timer.C
+	}
+
 	for {
 		select {
-		case <-timer.C:
+		case <-tick:
 			Task(taskctx, errc)
+		case meta := <-updates:
+			// a streamed record is a working connection
+			reportFetch(taskctx, nil, errc)
+			Process(taskctx, []feixiaohao.CoinPriceMeta{meta}, errc)
 		case erri := <-errc:
-			fmt.Println("error happend: %s", erri)
+			fmt.Printf("error happened: %s\n", erri)
+			// every failed reconnect count like a failed round
+			if _, ok := erri.(*source.DisconnectError); ok {
+				reportFetch(taskctx, erri, errc)
+			}
+		case <-hups:
+			config = reloadConfig(taskctx, config, reloader)
+		case <-reloads:
+			config = reloadConfig(taskctx, config, reloader)
 		case <-exit:
 			return
 		}
@@ -220,23 +634,109 @@ func main() {
 		},
 	)
 
+	// read the defaults, the config file then the flags into a new
+	// config, an empty configFile skip the file. The global flags live in
+	// the root context when called from a command
+	readOptions := func(c *cli.Context, configFile string) (*AppConfigOpt, error) {
+		c = rootContext(c)
+
+		options := &AppConfigOpt{}
+		if err := common.ApplyDefaultValues(options); err != nil {
+			return nil, err
+		}
+
+		if configFile != "" {
+			if err := common.ApplyConfigFile(configFile, options); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := common.ApplyFlags(cliFlags, flagMappings, c, options); err != nil {
+			return nil, err
+		}
+		return options, nil
+	}
+
+	readConfig := func(c *cli.Context) (*AppConfigOpt, error) {
+		configFile, ok := configPath(rootContext(c))
+		if !ok {
+			configFile = ""
+		}
+		return readOptions(c, configFile)
+	}
+
+	loadConfig := func(c *cli.Context) *AppConfigOpt {
+		options, err := readConfig(c)
+		if err != nil {
+			exit(err, 2)
+		}
+		return options
+	}
+
 	app.Action = func(c *cli.Context) {
+		reloader := &ConfigReloader{
+			Load: func() (*AppConfigOpt, error) {
+				return readConfig(c)
+			},
+		}
+		if configFile := homedir.Expand(c.String("config")); fileExists(configFile) {
+			reloader.File = configFile
+		}
+		Start(loadConfig(c), reloader)
+	}
 
-		configFile := c.String("config")
-		_, err := os.Stat(homedir.Expand(configFile))
-		if configFile != "config.yaml" || !os.IsNotExist(err) {
-			if err := common.ApplyConfigFileYaml(configFile, appOptions); err != nil {
-				exit(err, 2)
+	// tell where every value of the config came from
+	provenance := func(c *cli.Context) (map[string]string, error) {
+		fileKeys := make(map[string]bool)
+		if configFile, ok := configPath(rootContext(c)); ok {
+			keys, err := common.FileKeys(configFile)
+			if err != nil {
+				return nil, err
 			}
+			fileKeys = keys
 		}
+		return common.Provenance(&AppConfigOpt{}, app.Flags, os.Args[1:], os.LookupEnv, fileKeys)
+	}
 
-		common.ApplyFlags(cliFlags, flagMappings, c, appOptions)
-		Start(appOptions)
+	// init create the config file, so it may not exist yet
+	initConfig := func(c *cli.Context) *AppConfigOpt {
+		configFile := rootContext(c).String("config")
+		if !fileExists(homedir.Expand(configFile)) {
+			configFile = ""
+		}
+		options, err := readOptions(c, configFile)
+		if err != nil {
+			exit(err, 2)
+		}
+		return options
+	}
+
+	app.Commands = []cli.Command{
+		watchlistCommand(loadConfig),
+		configCommand(loadConfig, provenance),
+		initCommand(initConfig),
 	}
 
 	app.Run(os.Args)
 }
 
+// rootContext return the app context of a command context, the global
+// flags live there
+func rootContext(c *cli.Context) *cli.Context {
+	for c.Parent() != nil {
+		c = c.Parent()
+	}
+	return c
+}
+
+// configPath return the config file to read, the default config.yaml may
+// be missing
+func configPath(c *cli.Context) (string, bool) {
+	configFile := c.String("config")
+	_, err := os.Stat(homedir.Expand(configFile))
+	return configFile, configFile != "config.yaml" || !os.IsNotExist(err)
+}
+
 func exit(err error, code int) {
 	if err != nil {
 		fmt.Println(err)
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"errors"
 	"testing"
+	"github.com/smileboywtu/CoinNotify/binance"
 	"github.com/smileboywtu/CoinNotify/feixiaohao"
-	"github.com/smileboywtu/CoinNotify/aliyun"
+	"github.com/smileboywtu/CoinNotify/notify"
+	"github.com/smileboywtu/CoinNotify/price"
+	"github.com/smileboywtu/CoinNotify/rule"
+	"strings"
 	"time"
 )
 
@@ -13,12 +18,10 @@ func TestNeedNotify(t *testing.T) {
 	ctx := TaskContext{
 		LastNotifyTime:make(map[string]int64),
 		LastRecord: make(map[string]float32),
-		Cookies:nil,
+		Source:nil,
 		Filter:feixiaohao.CoinFilter{
 			TimePeriod:2,
 		},
-		AliyunCtx: aliyun.AliyunSMSOpt{
-		},
 	}
 
 	meta:= feixiaohao.CoinPriceMeta{
@@ -75,4 +78,158 @@ func TestNeedNotify(t *testing.T) {
 	<- done
 	t.Log("timeout notify", pricef)
 
-}
\ No newline at end of file
+}
+func TestNeedNotifyMuted(t *testing.T) {
+	ctx := NewTaskContext()
+	meta := feixiaohao.CoinPriceMeta{Percent: "5.2%", CoinType: "IOST"}
+
+	ctx.Mute(meta.CoinType, time.Now().Add(time.Hour))
+	if notify, _ := NeedNotify(meta, *ctx); notify {
+		t.Fatal("muted coin should not notify")
+	}
+
+	ctx.Mute(meta.CoinType, time.Time{})
+	if notify, _ := NeedNotify(meta, *ctx); !notify {
+		t.Fatal("unmuted coin should notify")
+	}
+}
+
+func TestNeedNotifyPriceLevel(t *testing.T) {
+	above := price.Value{Amount: 0.5, Currency: "CNY"}
+	ctx := NewTaskContext()
+	ctx.Filter = feixiaohao.CoinFilter{
+		High:       100,
+		Low:        -100,
+		Amplitude:  100,
+		TimePeriod: 3600,
+		Specs:      map[string]feixiaohao.CoinSpec{"CMT": {Symbol: "CMT", Above: &above}},
+	}
+	ctx.LastNotifyTime["CMT"] = time.Now().Unix()
+
+	for i, step := range []struct {
+		price  string
+		notify bool
+	}{
+		{"¥0.48", false},
+		{"¥0.51", true},
+		{"¥0.53", false},
+		{"¥0.49", false},
+		{"¥0.50", true},
+	} {
+		meta := feixiaohao.CoinPriceMeta{Price: step.price, Percent: "1.0%", CoinType: "CMT"}
+		if notify, _ := NeedNotify(meta, *ctx); notify != step.notify {
+			t.Fatal("step ", i, " price ", step.price, " expect notify ", step.notify)
+		}
+		ctx.LastPrice["CMT"], _ = price.Parse(step.price)
+	}
+}
+
+type recordNotifier struct {
+	alerts []notify.Alert
+}
+
+func (r *recordNotifier) Notify(alert notify.Alert) error {
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+func TestTaskBinance(t *testing.T) {
+	exchange := binance.NewFakeExchange(
+		binance.Ticker{Symbol: "CMTBTC", LastPrice: "0.00001234", PriceChangePercent: "5.20"},
+	)
+	defer exchange.Close()
+
+	config := &AppConfigOpt{Source: "binance", ExchangeURL: exchange.URL()}
+	pricesource, quit, err := NewPriceSource(config)
+	if err != nil || quit != nil {
+		t.Fatal("binance source should need no login: ", err)
+	}
+
+	recorder := &recordNotifier{}
+	ctx := NewTaskContext()
+	ctx.Source = pricesource
+	ctx.Filter = feixiaohao.CoinFilter{CoinType: []string{"CMT"}, High: 3, Low: -2, Amplitude: 1, TimePeriod: 3600}
+	ctx.Notifiers = []notify.Notifier{recorder}
+
+	errc := make(chan error, 2)
+	Task(ctx, errc)
+	if len(recorder.alerts) != 1 || recorder.alerts[0].Platform != "Binance" || recorder.alerts[0].Percent != "5.20%" {
+		t.Fatal("first round should alert CMT: ", recorder.alerts)
+	}
+
+	// small move is quiet
+	exchange.SetTicker(binance.Ticker{Symbol: "CMTBTC", LastPrice: "0.00001240", PriceChangePercent: "5.50"})
+	Task(ctx, errc)
+	if len(recorder.alerts) != 1 {
+		t.Fatal("small move should not alert: ", recorder.alerts)
+	}
+
+	exchange.SetTicker(binance.Ticker{Symbol: "CMTBTC", LastPrice: "0.00001300", PriceChangePercent: "7.00"})
+	Task(ctx, errc)
+	if len(recorder.alerts) != 2 || ctx.LastRecord["CMT"] != 7 {
+		t.Fatal("amplitude move should alert: ", recorder.alerts)
+	}
+
+	select {
+	case err := <-errc:
+		t.Fatal(err)
+	default:
+	}
+}
+
+func TestRecordFetchWarn(t *testing.T) {
+	ctx := NewTaskContext()
+	ctx.Source = feixiaohao.NewSource(feixiaohao.NewSession(feixiaohao.UserLoginMeta{}, ""))
+	ctx.Filter = feixiaohao.CoinFilter{CoinType: []string{"CMT"}}
+	ctx.FailureWarn = 2
+
+	layout := &feixiaohao.LayoutError{Reason: "userticker table not found"}
+	if alert := ctx.recordFetch(layout); alert != nil {
+		t.Fatal("first failure should not warn: ", alert)
+	}
+	alert := ctx.recordFetch(layout)
+	if alert == nil || !strings.Contains(alert.Reason, "layout changed") {
+		t.Fatal("second failure should warn layout change: ", alert)
+	}
+	if alert := ctx.recordFetch(layout); alert != nil {
+		t.Fatal("warn only once: ", alert)
+	}
+	if alert := ctx.recordFetch(nil); alert == nil || alert.Reason != "price source recovered" {
+		t.Fatal("recover should be notified: ", alert)
+	}
+
+	if !alert.Warning() || alert.Price != "" {
+		t.Fatal("failure warning should be a warning without price: ", alert)
+	}
+
+	// the other coins were read, missing ones are not a failed round
+	ctx.recordFetch(&feixiaohao.NotWatchedError{Coins: []string{"CMT"}})
+	if alert := ctx.recordFetch(&feixiaohao.NotWatchedError{Coins: []string{"CMT"}}); alert != nil || ctx.FetchFailures != 0 {
+		t.Fatal("missing coins should not count as failure: ", alert)
+	}
+	ctx.recordFetch(errors.New("connection refused"))
+	if alert := ctx.recordFetch(errors.New("connection refused")); alert == nil || !alert.Warning() {
+		t.Fatal("should warn failing source: ", alert)
+	}
+}
+
+func TestNeedNotifySpecWithRules(t *testing.T) {
+	high := float32(8)
+	ctx := NewTaskContext()
+	ctx.Rules = []rule.Rule{{Condition: rule.PercentAbove, Value: 20}}
+	ctx.Filter = feixiaohao.CoinFilter{
+		High:  3,
+		Specs: map[string]feixiaohao.CoinSpec{"CMT": {Symbol: "CMT", High: &high}, "IOST": {Symbol: "IOST"}},
+	}
+
+	_, _, matched := checkNotify(feixiaohao.CoinPriceMeta{Percent: "9%", CoinType: "CMT"}, *ctx)
+	if len(matched) != 1 || matched[0].Value != 8 {
+		t.Fatal("coin high should add to the rules: ", matched)
+	}
+	if notify, _ := NeedNotify(feixiaohao.CoinPriceMeta{Percent: "9%", CoinType: "IOST"}, *ctx); notify {
+		t.Fatal("coin without thresholds only follow the rules")
+	}
+	if len(ctx.Rules) != 1 {
+		t.Fatal("rules should not grow: ", ctx.Rules)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/smileboywtu/CoinNotify/aliyun"
+	"github.com/smileboywtu/CoinNotify/dingtalk"
+	mailer "github.com/smileboywtu/CoinNotify/email"
+	"github.com/smileboywtu/CoinNotify/notify"
+	"github.com/smileboywtu/CoinNotify/rule"
+	"github.com/smileboywtu/CoinNotify/telegram"
+	"github.com/smileboywtu/CoinNotify/webhook"
+	"github.com/smileboywtu/CoinNotify/wecom"
+)
+
+// defaultNotifiers is used when config select no notifier
+var defaultNotifiers = []string{"aliyun"}
+
+// NewNotifierRegistry register every notifier backend built from config
+func NewNotifierRegistry(config *AppConfigOpt) (*notify.Registry, error) {
+	registry := notify.NewRegistry()
+	registry.Register("aliyun", aliyun.NewNotifier(aliyun.AliyunSMSOpt{
+		AccessKey:        config.AccessKey,
+		AccessID:         config.AccessID,
+		SignName:         config.SignName,
+		TemplateCode:     config.TemplateCode,
+		WarnTemplateCode: config.WarnTemplateCode,
+		NotifyPhone:      config.NotifyPhone,
+	}))
+
+	headers, err := webhook.ParseHeaders(config.WebhookHeaders)
+	if err != nil {
+		return nil, err
+	}
+	hook, err := webhook.NewNotifier(webhook.WebhookOpt{
+		URL:        config.WebhookURL,
+		Template:   config.WebhookTemplate,
+		Headers:    headers,
+		Secret:     config.WebhookSecret,
+		MaxRetries: config.WebhookRetry,
+	})
+	if err != nil {
+		return nil, err
+	}
+	registry.Register("webhook", hook)
+
+	registry.Register("email", mailer.NewNotifier(mailer.EmailOpt{
+		Host:     config.SMTPHost,
+		Port:     config.SMTPPort,
+		Username: config.SMTPUser,
+		Password: config.SMTPPassword,
+		From:     config.MailFrom,
+		To:       config.MailTo,
+		Subject:  config.MailSubject,
+
+		AllowPlaintext: config.SMTPPlaintext,
+	}))
+
+	registry.Register("telegram", NewTelegramBot(config))
+
+	// group robots are registered per chat as dingtalk:<name> and wecom:<name>
+	for i, robot := range config.DingTalk {
+		if robot.Name == "" {
+			// the webhook contains the token, keep it out of logs
+			return nil, fmt.Errorf("dingtalk robot %d has no name", i+1)
+		}
+		registry.Register("dingtalk:"+robot.Name, dingtalk.NewRobot(dingtalk.RobotOpt{
+			Webhook: robot.Webhook,
+			Secret:  robot.Secret,
+			MsgType: robot.MsgType,
+		}))
+	}
+	for i, robot := range config.WeCom {
+		if robot.Name == "" {
+			// the webhook contains the token, keep it out of logs
+			return nil, fmt.Errorf("wecom robot %d has no name", i+1)
+		}
+		registry.Register("wecom:"+robot.Name, wecom.NewRobot(wecom.RobotOpt{
+			Webhook: robot.Webhook,
+			MsgType: robot.MsgType,
+		}))
+	}
+
+	return registry, nil
+}
+
+// NewTelegramBot create the telegram bot used both as notifier and for chat commands
+func NewTelegramBot(config *AppConfigOpt) *telegram.Bot {
+	return telegram.NewBot(telegram.TelegramOpt{
+		Token:   config.TelegramToken,
+		ChatIDs: config.TelegramChatIDs,
+		BaseURL: config.TelegramAPI,
+	})
+}
+
+// NewNotifiers return the registry and the default notifiers selected by config.Notifiers
+func NewNotifiers(config *AppConfigOpt) (*notify.Registry, []notify.Notifier, error) {
+	names := config.Notifiers
+	if len(names) == 0 {
+		names = defaultNotifiers
+	}
+	registry, err := NewNotifierRegistry(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	notifiers, err := registry.Select(names)
+	if err != nil {
+		return nil, nil, err
+	}
+	return registry, notifiers, nil
+}
+
+// ValidateRules check every rule, that its channels are registered and
+// that no two rules share a name, the name keep the cooldown of the rule
+func ValidateRules(rules []rule.Rule, registry *notify.Registry) error {
+	names := make(map[string]bool)
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		if r.Name != "" {
+			if names[r.Name] {
+				return fmt.Errorf("rule %s: name used by another rule", r.Name)
+			}
+			names[r.Name] = true
+		}
+		if _, err := registry.Select(r.Channels); err != nil {
+			return fmt.Errorf("rule %s: %s", r.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package notify defines the alert channel used to tell the user about a price move
+package notify
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// KindWarning mark an alert about the watcher itself, like a failing price
+// source. It has no price and percent, the notifiers render the reason
+const KindWarning = "warning"
+
+// Alert is one triggered price move
+type Alert struct {
+	Kind     string `json:"kind"`
+	CoinType string `json:"cointype"`
+	Platform string `json:"platform"`
+	Price    string `json:"price"`
+	Percent  string `json:"percent"`
+	Reason   string `json:"reason"`
+}
+
+// Warning tell if the alert is a warning without price
+func (a Alert) Warning() bool {
+	return a.Kind == KindWarning
+}
+
+// Notifier deliver an alert to the user
+type Notifier interface {
+	Notify(alert Alert) error
+}
+
+// BatchNotifier deliver all the alerts of one task round in a single message
+type BatchNotifier interface {
+	Notifier
+	NotifyBatch(alerts []Alert) error
+}
+
+// Registry keep all the notifiers that can be selected by name
+type Registry struct {
+	notifiers map[string]Notifier
+}
+
+func NewRegistry() *Registry {
+	return &Registry{notifiers: make(map[string]Notifier)}
+}
+
+// Register add notifier with name, an existing notifier with the same name is replaced
+func (r *Registry) Register(name string, notifier Notifier) {
+	r.notifiers[name] = notifier
+}
+
+// Get return the notifier registered with name
+func (r *Registry) Get(name string) (Notifier, bool) {
+	notifier, ok := r.notifiers[name]
+	return notifier, ok
+}
+
+// Names return the registered names in order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.notifiers))
+	for name := range r.notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Select return the notifiers for names, fails on the first unknown name
+func (r *Registry) Select(names []string) ([]Notifier, error) {
+	notifiers := make([]Notifier, 0, len(names))
+	for _, name := range names {
+		notifier, ok := r.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown notifier: %s, available: %v", name, r.Names())
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	return notifiers, nil
+}
+
+// Dispatch send alert through every notifier and collect all the errors
+func Dispatch(notifiers []Notifier, alert Alert) error {
+	var result error
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(alert); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	return result
+}
+
+// DispatchBatch send the alerts of one task round, batch notifiers get all
+// of them at once and the others get them one by one
+func DispatchBatch(notifiers []Notifier, alerts []Alert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	var result error
+	for _, notifier := range notifiers {
+		if batch, ok := notifier.(BatchNotifier); ok {
+			if err := batch.NotifyBatch(alerts); err != nil {
+				result = multierror.Append(result, err)
+			}
+			continue
+		}
+		for _, alert := range alerts {
+			if err := notifier.Notify(alert); err != nil {
+				result = multierror.Append(result, err)
+			}
+		}
+	}
+	return result
+}
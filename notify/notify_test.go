@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"errors"
+	"testing"
+)
+
+type recordNotifier struct {
+	alerts []Alert
+	err    error
+}
+
+func (r *recordNotifier) Notify(alert Alert) error {
+	r.alerts = append(r.alerts, alert)
+	return r.err
+}
+
+func TestRegistrySelect(t *testing.T) {
+	registry := NewRegistry()
+	first := &recordNotifier{}
+	second := &recordNotifier{err: errors.New("channel down")}
+	registry.Register("first", first)
+	registry.Register("second", second)
+
+	if _, err := registry.Select([]string{"first", "missing"}); err == nil {
+		t.Fatal("select unknown notifier should fail")
+	}
+
+	notifiers, err := registry.Select([]string{"first", "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Dispatch(notifiers, Alert{CoinType: "CMT", Percent: "5.2%"})
+	if err == nil {
+		t.Fatal("dispatch should report the failed channel")
+	}
+	if len(first.alerts) != 1 || len(second.alerts) != 1 {
+		t.Fatal("alert should reach every notifier: ", first.alerts, second.alerts)
+	}
+}
+
+type batchNotifier struct {
+	recordNotifier
+	batches [][]Alert
+}
+
+func (b *batchNotifier) NotifyBatch(alerts []Alert) error {
+	b.batches = append(b.batches, alerts)
+	return nil
+}
+
+func TestDispatchBatch(t *testing.T) {
+	single := &recordNotifier{}
+	batch := &batchNotifier{}
+	alerts := []Alert{{CoinType: "CMT"}, {CoinType: "IOST"}}
+
+	if err := DispatchBatch([]Notifier{single, batch}, alerts); err != nil {
+		t.Fatal(err)
+	}
+	if len(single.alerts) != 2 {
+		t.Fatal("plain notifier should get every alert: ", single.alerts)
+	}
+	if len(batch.batches) != 1 || len(batch.batches[0]) != 2 || len(batch.alerts) != 0 {
+		t.Fatal("batch notifier should get one batch: ", batch.batches)
+	}
+}
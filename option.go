@@ -1,17 +1,101 @@
 package main
 
+import (
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+	"github.com/smileboywtu/CoinNotify/rule"
+)
+
+// RobotOpt is one group chat robot
+type RobotOpt struct {
+	Name    string `yaml:"name"`
+	Webhook string `yaml:"webhook"`
+	Secret  string `yaml:"secret"`
+	MsgType string `yaml:"msgtype"`
+}
+
 type AppConfigOpt struct {
 	// aliyun config
 	AccessKey    string `yaml:"accesskey" flagName:"accesskey" flagSName:"ak" flagDescribe:"Aliyun SMS AccessKey" default:""`
 	AccessID     string `yaml:"accessid" flagName:"accessid" flagSName:"ai" flagDescribe:"Aliyun SMS AccessID" default:""`
 	SignName     string `yaml:"signname" flagName:"signname" flagSName:"sn" flagDescribe:"Aliyun SMS Sign Name" default:""`
 	TemplateCode string `yaml:"templatecode" flagName:"templatecode" flagSName:"tc" flagDescribe:"Aliyun SMS Template Code" default:""`
+	// template with platform, cointype and reason slots for source warnings
+	WarnTemplateCode string `yaml:"warntemplatecode" flagName:"warntemplatecode" flagSName:"wtc" flagDescribe:"Aliyun SMS Template Code of warnings, empty to skip them" default:""`
 
 	// feixiaohao
 	UserName string `yaml:"userid" flagName:"userid" flagSName:"u" flagDescribe:"Feixiaohao userid" default:""`
 	PassWD   string `yaml:"passwd" flagName:"passwd" flagSName:"p" flagDescribe:"Feixiaohao password" default:""`
 
+	// feixiaohao login cookies cache, empty to login on every start
+	SessionFile string `yaml:"sessionfile" flagName:"sessionfile" flagSName:"ssf" flagDescribe:"Feixiaohao login cookies cache file" default:"~/.coinnotify/session.json"`
+	// FeixiaohaoInterval is the time between two reads of the watchlist page
+	FeixiaohaoInterval time.Duration `yaml:"feixiaohaointerval" flagName:"feixiaohaointerval" flagSName:"fi" flagDescribe:"Feixiaohao poll interval" default:"2s"`
+
+	// price source
+	Source string `yaml:"source" flagName:"source" flagSName:"src" flagDescribe:"Coin price data source, feixiaohao, binance or coingecko" default:"feixiaohao"`
+
+	SourceMode string `yaml:"sourcemode" flagName:"sourcemode" flagSName:"sm" flagDescribe:"Source mode, poll or stream (binance only)" default:"poll"`
+
+	// several sources watched at once, combined into a consensus record
+	Sources   []string `yaml:"sources" flagName:"sources" flagSplit:"true" flagSName:"srcs" flagDescribe:"Price sources to combine, override source" default:""`
+	Aggregate string   `yaml:"aggregate" flagName:"aggregate" flagSName:"agg" flagDescribe:"Combine method, median, weighted or failover" default:"median"`
+	Tolerance float64  `yaml:"tolerance" flagName:"tolerance" flagSName:"tol" flagDescribe:"Drop records deviating from the median by more percent" default:"5.0"`
+
+	// notify when failover switch the source of a coin
+	FailoverNotify bool `yaml:"failovernotify" flagName:"failovernotify" flagSName:"fn" flagDescribe:"Notify when failover switch the source of a coin" default:"false"`
+
+	// warn after this many failed fetch rounds in a row, 0 to disable
+	FailureWarn int `yaml:"failurewarn" flagName:"failurewarn" flagSName:"fw" flagDescribe:"Notify after this many failed fetch rounds in a row, 0 to disable" default:"3"`
+
+	// binance compatible exchange
+	ExchangeURL    string   `yaml:"exchangeurl" flagName:"exchangeurl" flagSName:"eu" flagDescribe:"Binance compatible exchange api base url" default:"https://api.binance.com"`
+	ExchangeName   string   `yaml:"exchangename" flagName:"exchangename" flagSName:"en" flagDescribe:"Exchange name shown as platform" default:"Binance"`
+	StreamURL      string   `yaml:"streamurl" flagName:"streamurl" flagSName:"wsu" flagDescribe:"Exchange ticker websocket url for stream mode" default:"wss://stream.binance.com:9443/ws"`
+	ExchangeQuotes []string `yaml:"exchangequotes" flagName:"exchangequotes" flagSplit:"true" flagSName:"eq" flagDescribe:"Quote assets in preference order" default:""`
+	// ExchangeInterval keep the polling under the exchange request weight limit
+	ExchangeInterval time.Duration `yaml:"exchangeinterval" flagName:"exchangeinterval" flagSName:"ei" flagDescribe:"Exchange poll interval" default:"10s"`
+
+	// coingecko style aggregator api
+	AggregatorURL      string            `yaml:"aggregatorurl" flagName:"aggregatorurl" flagSName:"agu" flagDescribe:"Aggregator api base url" default:"https://api.coingecko.com/api/v3"`
+	AggregatorCurrency string            `yaml:"aggregatorcurrency" flagName:"aggregatorcurrency" flagSName:"agc" flagDescribe:"Aggregator quote currency, like usd or cny" default:"usd"`
+	AggregatorKey      string            `yaml:"aggregatorkey" flagName:"aggregatorkey" flagSName:"agk" flagDescribe:"Aggregator api key, optional" default:""`
+	AggregatorIDs      map[string]string `yaml:"aggregatorids"`
+	// AggregatorInterval keep the polling under the free api rate limit
+	AggregatorInterval time.Duration `yaml:"aggregatorinterval" flagName:"aggregatorinterval" flagSName:"agi" flagDescribe:"Aggregator poll interval" default:"30s"`
+
+	// webhook
+	WebhookURL      string   `yaml:"webhookurl" flagName:"webhookurl" flagSName:"wu" flagDescribe:"Webhook notify url" default:""`
+	WebhookTemplate string   `yaml:"webhooktemplate" flagName:"webhooktemplate" flagSName:"wt" flagDescribe:"Webhook body text/template" default:""`
+	WebhookSecret   string   `yaml:"webhooksecret" flagName:"webhooksecret" flagSName:"ws" flagDescribe:"Webhook HMAC-SHA256 signing secret" default:""`
+	WebhookHeaders  []string `yaml:"webhookheaders" flagName:"webhookheaders" flagSName:"wh" flagDescribe:"Webhook extra headers, Key: Value" default:""`
+	WebhookRetry    int      `yaml:"webhookretry" flagName:"webhookretry" flagSName:"wr" flagDescribe:"Webhook retry times on 5xx" default:"2"`
+
+	// email
+	SMTPHost      string   `yaml:"smtphost" flagName:"smtphost" flagSName:"sh" flagDescribe:"SMTP server host" default:""`
+	SMTPPort      int      `yaml:"smtpport" flagName:"smtpport" flagSName:"sp" flagDescribe:"SMTP server port, STARTTLS is required for auth" default:"587"`
+	SMTPUser      string   `yaml:"smtpuser" flagName:"smtpuser" flagSName:"su" flagDescribe:"SMTP PLAIN auth user" default:""`
+	SMTPPassword  string   `yaml:"smtppassword" flagName:"smtppassword" flagSName:"spw" flagDescribe:"SMTP PLAIN auth password" default:""`
+	SMTPPlaintext bool     `yaml:"smtpplaintext" flagName:"smtpplaintext" flagSName:"spt" flagDescribe:"Allow SMTP auth without STARTTLS" default:"false"`
+	MailFrom      string   `yaml:"mailfrom" flagName:"mailfrom" flagSName:"mf" flagDescribe:"Mail sender, default smtp user" default:""`
+	MailTo        []string `yaml:"mailto" flagName:"mailto" flagSplit:"true" flagSName:"mt" flagDescribe:"Mail receiver list" default:""`
+	MailSubject   string   `yaml:"mailsubject" flagName:"mailsubject" flagSName:"ms" flagDescribe:"Mail subject prefix" default:"Coin Price Notify"`
+
+	// telegram
+	TelegramToken   string   `yaml:"telegramtoken" flagName:"telegramtoken" flagSName:"tgt" flagDescribe:"Telegram bot token, also enable chat commands" default:""`
+	TelegramChatIDs []string `yaml:"telegramchatids" flagName:"telegramchatids" flagSplit:"true" flagSName:"tgc" flagDescribe:"Telegram chat id list" default:""`
+	TelegramAPI     string   `yaml:"telegramapi" flagName:"telegramapi" flagSName:"tga" flagDescribe:"Telegram bot api base url" default:"https://api.telegram.org"`
+
+	// group chat robots, only from config file
+	DingTalk []RobotOpt `yaml:"dingtalk"`
+	WeCom    []RobotOpt `yaml:"wecom"`
+
+	// state
+	StateFile string `yaml:"statefile" flagName:"statefile" flagSName:"sf" flagDescribe:"Notify state file kept across restarts, empty to disable" default:"~/.coinnotify/state.json"`
+
 	// notify
+	Notifiers []string `yaml:"notifiers" flagName:"notifiers" flagSplit:"true" flagSName:"nf" flagDescribe:"Enabled notifier list, default aliyun" default:""`
 
 	NotifyPhone      string  `yaml:"notifyphone" flagName:"notifyphone" flagSName:"np" flagDescribe:"User notify phone number" default:""`
 	NotifyTimePeriod int64   `yaml:"notifytimeperiod" flagName:"notifytimeperiod" flagSName:"ntp" flagDescribe:"SMS notify time period" default:"3600"`
@@ -19,5 +103,12 @@ type AppConfigOpt struct {
 	PriceHighPercent float32 `yaml:"highpricepercent" flagName:"highpricepercent" flagSName:"hp" flagDescribe:"Coin Price high percent" default:"3.0"`
 	PriceAmplitude   float32 `yaml:"amplitude" flagName:"amplitude" flagSName:"apt" flagDescribe:"Coin Price amplitude" default:"1.0"`
 
-	CoinTypes []string `yaml:"cointype" flagName:"cointype" flagSName:"ct" flagDescribe:"Monitor coin type list" default:""`
+	// Rules replace the high/low/amplitude defaults, only from config file
+	Rules []rule.Rule `yaml:"rules"`
+
+	// Aliases map other names of coins to symbols, like 比特币: BTC
+	Aliases map[string]string `yaml:"aliases"`
+
+	// CoinTypes entries are a symbol or a map with per coin thresholds
+	CoinTypes feixiaohao.CoinList `yaml:"cointype" flagName:"cointype" flagSplit:"true" flagSName:"ct" flagDescribe:"Monitor coin type list" default:""`
 }
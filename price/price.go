@@ -0,0 +1,180 @@
+// Package price parse the price strings shown by the data providers
+package price
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Value is a parsed price, Currency is empty when the text carries no currency
+type Value struct {
+	Amount   float64
+	Currency string
+}
+
+var symbols = map[string]string{
+	"¥": "CNY",
+	"￥": "CNY",
+	"$": "USD",
+	"€": "EUR",
+	"£": "GBP",
+	"₩": "KRW",
+	"฿": "BTC",
+	"₿": "BTC",
+	"Ξ": "ETH",
+}
+
+// aliases map the currencies pegged to another one, the dollar stable
+// coins count as USD
+var aliases = map[string]string{
+	"USDT":  "USD",
+	"USDC":  "USD",
+	"BUSD":  "USD",
+	"TUSD":  "USD",
+	"FDUSD": "USD",
+	"USDP":  "USD",
+	"DAI":   "USD",
+	"RMB":   "CNY",
+	"CNH":   "CNY",
+}
+
+// Canonical return the currency that currency is pegged to, or itself
+func Canonical(currency string) string {
+	if canonical, ok := aliases[strings.ToUpper(currency)]; ok {
+		return canonical
+	}
+	return currency
+}
+
+var multipliers = map[string]float64{
+	"万": 1e4,
+	"亿": 1e8,
+	"万亿": 1e12,
+}
+
+// Parse read price text like "¥3,200.5", "$0.52", "1.2万", "0.00012 BTC" or "1.5亿元"
+func Parse(text string) (Value, error) {
+	var value Value
+	s := strings.TrimSpace(text)
+	if s == "" {
+		return value, fmt.Errorf("empty price")
+	}
+
+	negative := false
+	if strings.HasPrefix(s, "-") {
+		negative = true
+		s = strings.TrimSpace(s[1:])
+	}
+
+	// leading currency symbol or code
+	for symbol, currency := range symbols {
+		if strings.HasPrefix(s, symbol) {
+			value.Currency = currency
+			s = strings.TrimSpace(strings.TrimPrefix(s, symbol))
+			break
+		}
+	}
+	if value.Currency == "" {
+		if code := leadingCode(s); code != "" {
+			value.Currency = code
+			s = strings.TrimSpace(s[len(code):])
+		}
+	}
+	if !negative && strings.HasPrefix(s, "-") {
+		negative = true
+		s = strings.TrimSpace(s[1:])
+	}
+
+	// split the number from the suffix
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.' && r != ','
+	})
+	number, suffix := s, ""
+	if end >= 0 {
+		number, suffix = s[:end], strings.TrimSpace(s[end:])
+	}
+
+	number = strings.Replace(number, ",", "", -1)
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return value, fmt.Errorf("invalid price %q", text)
+	}
+
+	// trailing 元 is yuan, check the longest multiplier first
+	if strings.HasSuffix(suffix, "元") {
+		suffix = strings.TrimSpace(strings.TrimSuffix(suffix, "元"))
+		if value.Currency == "" {
+			value.Currency = "CNY"
+		}
+	}
+	for _, unit := range []string{"万亿", "万", "亿"} {
+		if strings.HasPrefix(suffix, unit) {
+			amount *= multipliers[unit]
+			suffix = strings.TrimSpace(strings.TrimPrefix(suffix, unit))
+			break
+		}
+	}
+	if suffix != "" {
+		code := leadingCode(suffix)
+		if code == "" || code != suffix {
+			return value, fmt.Errorf("invalid price %q", text)
+		}
+		if value.Currency != "" && value.Currency != code {
+			return value, fmt.Errorf("price %q has two currencies", text)
+		}
+		value.Currency = code
+	}
+
+	if negative {
+		amount = -amount
+	}
+	value.Amount = amount
+	return value, nil
+}
+
+// leadingCode return the upper case letters at the start of s, like USDT
+func leadingCode(s string) string {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return r < 'A' || r > 'z' || (r > 'Z' && r < 'a')
+	})
+	if end < 0 {
+		end = len(s)
+	}
+	if end < 2 {
+		return ""
+	}
+	return strings.ToUpper(s[:end])
+}
+
+// Comparable tell if two values can be compared, an empty currency match
+// any and pegged currencies match, like USDT and USD
+func (v Value) Comparable(other Value) bool {
+	return v.Currency == "" || other.Currency == "" || Canonical(v.Currency) == Canonical(other.Currency)
+}
+
+func (v Value) String() string {
+	amount := strconv.FormatFloat(v.Amount, 'f', -1, 64)
+	if v.Currency == "" {
+		return amount
+	}
+	return amount + " " + v.Currency
+}
+
+func (v *Value) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var text string
+	if err := unmarshal(&text); err != nil {
+		return err
+	}
+	parsed, err := Parse(text)
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+func (v Value) MarshalYAML() (interface{}, error) {
+	return v.String(), nil
+}
@@ -0,0 +1,40 @@
+package price
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		text  string
+		value Value
+	}{
+		{"3.2", Value{3.2, ""}},
+		{" ¥3,200.5 ", Value{3200.5, "CNY"}},
+		{"￥0.52", Value{0.52, "CNY"}},
+		{"$0.52", Value{0.52, "USD"}},
+		{"-$1.5", Value{-1.5, "USD"}},
+		{"1.2万", Value{12000, ""}},
+		{"¥1.5亿", Value{1.5e8, "CNY"}},
+		{"3.5亿元", Value{3.5e8, "CNY"}},
+		{"0.00012 BTC", Value{0.00012, "BTC"}},
+		{"USDT 1.02", Value{1.02, "USDT"}},
+	}
+
+	for _, c := range cases {
+		value, err := Parse(c.text)
+		if err != nil {
+			t.Errorf("%q: %s", c.text, err)
+			continue
+		}
+		if value != c.value {
+			t.Errorf("%q: expect %v, got %v", c.text, c.value, value)
+		}
+	}
+
+	for _, text := range []string{"", "--", "abc", "1.2x", "¥1 USD"} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("%q should fail", text)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/structs"
+	"github.com/smileboywtu/CoinNotify/common"
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+	"github.com/smileboywtu/CoinNotify/notify"
+	"github.com/smileboywtu/CoinNotify/rule"
+	"github.com/smileboywtu/CoinNotify/source"
+)
+
+// ConfigReloader read the config again for hot reload
+type ConfigReloader struct {
+	// File is the config file watched for changes, empty to only reload
+	// on SIGHUP
+	File string
+	// Load read defaults, file and flags into a new config
+	Load func() (*AppConfigOpt, error)
+}
+
+// Reload is a validated config ready to swap into a running TaskContext
+type Reload struct {
+	Config    *AppConfigOpt
+	Filter    feixiaohao.CoinFilter
+	Rules     []rule.Rule
+	Notifiers []notify.Notifier
+	Registry  *notify.Registry
+}
+
+// restartFields can not change without restart, the source and the chat
+// bot are built once
+var restartFields = []string{
+	"UserName", "PassWD", "SessionFile",
+	"FeixiaohaoInterval", "ExchangeInterval", "AggregatorInterval",
+	"Source", "SourceMode", "Sources", "Aggregate", "Tolerance", "FailoverNotify",
+	"ExchangeURL", "ExchangeName", "StreamURL", "ExchangeQuotes",
+	"AggregatorURL", "AggregatorCurrency", "AggregatorKey", "AggregatorIDs",
+	"TelegramToken", "TelegramChatIDs", "TelegramAPI",
+	"StateFile",
+}
+
+// NewReload validate config and build what the TaskContext swap in
+func NewReload(config *AppConfigOpt) (*Reload, error) {
+	registry, notifiers, err := NewNotifiers(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateRules(config.Rules, registry); err != nil {
+		return nil, err
+	}
+	return &Reload{
+		Config:    config,
+		Filter:    NewCoinFilter(config),
+		Rules:     config.Rules,
+		Notifiers: notifiers,
+		Registry:  registry,
+	}, nil
+}
+
+// Apply swap the filter, rules and notifiers at once and drop the state
+// of the coins no longer watched
+func (ctx *TaskContext) Apply(reload *Reload) {
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+
+	ctx.Filter = reload.Filter
+	ctx.Rules = reload.Rules
+	ctx.Notifiers = reload.Notifiers
+	ctx.Registry = reload.Registry
+	ctx.FailureWarn = reload.Config.FailureWarn
+
+	if len(ctx.Filter.CoinType) > 0 {
+		for _, state := range []interface{}{ctx.LastNotifyTime, ctx.LastRecord, ctx.MuteUntil, ctx.LastAlertRecord, ctx.LastPrice, ctx.LastRuleNotify} {
+			keys := reflect.ValueOf(state)
+			for _, key := range keys.MapKeys() {
+				if !feixiaohao.HasSymbol(ctx.Filter.CoinType, key.String()) {
+					keys.SetMapIndex(key, reflect.Value{})
+				}
+			}
+		}
+	}
+	if err := ctx.saveState(); err != nil {
+		fmt.Printf("save state error: %s\n", err)
+	}
+}
+
+// reloadConfig read and validate the config and swap it into ctx, a bad
+// config is reported and the running one kept
+func reloadConfig(ctx *TaskContext, current *AppConfigOpt, reloader *ConfigReloader) *AppConfigOpt {
+	config, err := reloader.Load()
+	if err == nil {
+		var reload *Reload
+		if reload, err = NewReload(config); err == nil {
+			ctx.Apply(reload)
+			if stream, ok := ctx.Source.(source.StreamSource); ok {
+				stream.Subscribe(reload.Filter)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Printf("reload config error, keep the running config: %s\n", err)
+		return current
+	}
+
+	diff := ConfigDiff(current, config)
+	if len(diff) == 0 {
+		fmt.Println("config reloaded, nothing changed")
+		return config
+	}
+	fmt.Println("config reloaded:")
+	for _, line := range diff {
+		fmt.Println("  " + line)
+	}
+	return config
+}
+
+// ConfigDiff describe the changed fields by yaml name, secrets are not
+// printed
+func ConfigDiff(old, new *AppConfigOpt) []string {
+	diff := make([]string, 0)
+	newfields := structs.New(new)
+	for _, field := range structs.New(old).Fields() {
+		before, after := field.Value(), newfields.Field(field.Name()).Value()
+		if reflect.DeepEqual(before, after) {
+			continue
+		}
+
+		name := strings.Split(field.Tag("yaml"), ",")[0]
+		if name == "" {
+			name = field.Name()
+		}
+		line := fmt.Sprintf("%s: %v -> %v", name, redactURL(before), redactURL(after))
+		if secretField(field.Name()) {
+			line = name + ": changed"
+		}
+		for _, restart := range restartFields {
+			if restart == field.Name() {
+				line += " (restart needed)"
+			}
+		}
+		diff = append(diff, line)
+	}
+	return diff
+}
+
+// secretField tell if the field hold a credential, the robots and headers
+// carry tokens too
+func secretField(name string) bool {
+	for _, word := range []string{"Key", "Secret", "PassWD", "Password", "Token", "Headers", "DingTalk", "WeCom"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL hide the query values and password of a url string, webhook
+// urls carry their token there. Other values are returned as they are
+func redactURL(value interface{}) interface{} {
+	text, ok := value.(string)
+	if !ok {
+		return value
+	}
+	address, err := url.Parse(text)
+	if err != nil || address.Scheme == "" || address.Host == "" {
+		return value
+	}
+	if address.RawQuery != "" {
+		keys := make([]string, 0)
+		for key := range address.Query() {
+			keys = append(keys, url.QueryEscape(key)+"="+redacted)
+		}
+		sort.Strings(keys)
+		address.RawQuery = strings.Join(keys, "&")
+	}
+	if _, ok := address.User.Password(); ok {
+		address.User = url.UserPassword(address.User.Username(), redacted)
+	}
+	return address.String()
+}
+
+// WatchConfigFile send to changed when the modify time or size of path, or
+// of a file it include or a drop-in beside it, change. Files added or
+// removed count as a change too. It stop when quit close
+func WatchConfigFile(path string, interval time.Duration, changed chan<- struct{}, quit <-chan struct{}) {
+	type stamp struct {
+		modtime time.Time
+		size    int64
+	}
+	stat := func() map[string]stamp {
+		paths, err := common.ConfigFiles(path)
+		if err != nil {
+			// a file saved half way may break the includes
+			paths = []string{path}
+		}
+		stamps := make(map[string]stamp, len(paths))
+		for _, each := range paths {
+			if info, err := os.Stat(each); err == nil {
+				stamps[each] = stamp{info.ModTime(), info.Size()}
+			}
+		}
+		return stamps
+	}
+
+	stamps := stat()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			newstamps := stat()
+			if len(newstamps) == 0 || reflect.DeepEqual(newstamps, stamps) {
+				continue
+			}
+			stamps = newstamps
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		case <-quit:
+			return
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/common"
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+	"github.com/smileboywtu/CoinNotify/rule"
+)
+
+func TestConfigDiff(t *testing.T) {
+	old := &AppConfigOpt{}
+	if err := common.ApplyDefaultValues(old); err != nil {
+		t.Fatal(err)
+	}
+	old.PriceHighPercent = 3
+	new := *old
+	new.PriceHighPercent = 8
+	new.SMTPPassword = "hunter2"
+	new.Source = "binance"
+	new.WebhookURL = "https://hooks.example.com/send?token=abc123"
+
+	diff := strings.Join(ConfigDiff(old, &new), "\n")
+	if !strings.Contains(diff, "highpricepercent: 3 -> 8") {
+		t.Fatal("threshold change missing: ", diff)
+	}
+	if strings.Contains(diff, "hunter2") || !strings.Contains(diff, "smtppassword: changed") {
+		t.Fatal("secret should be redacted: ", diff)
+	}
+	if strings.Contains(diff, "abc123") || !strings.Contains(diff, "send?token=******") {
+		t.Fatal("webhook token should be redacted: ", diff)
+	}
+	if !strings.Contains(diff, "source: feixiaohao -> binance (restart needed)") {
+		t.Fatal("source change should need restart: ", diff)
+	}
+	if len(ConfigDiff(old, old)) != 0 {
+		t.Fatal("same config should have no diff")
+	}
+}
+
+func TestApplyReload(t *testing.T) {
+	ctx := NewTaskContext()
+	ctx.Filter = feixiaohao.CoinFilter{CoinType: []string{"CMT", "IOST"}}
+	ctx.LastNotifyTime["CMT"], ctx.LastRecord["CMT"] = 100, 1.5
+	ctx.LastNotifyTime["IOST"], ctx.LastRecord["IOST"] = 200, 2.5
+
+	config := &AppConfigOpt{PriceHighPercent: 6}
+	config.CoinTypes = feixiaohao.CoinList{{Symbol: "CMT"}, {Symbol: "BTC"}}
+	reload, err := NewReload(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Apply(reload)
+
+	if ctx.Filter.High != 6 || len(ctx.Filter.CoinType) != 2 || ctx.Filter.CoinType[1] != "BTC" {
+		t.Fatal("filter not swapped: ", ctx.Filter)
+	}
+	if ctx.LastNotifyTime["CMT"] != 100 || ctx.LastRecord["CMT"] != 1.5 {
+		t.Fatal("state of CMT should be kept")
+	}
+	if _, ok := ctx.LastRecord["IOST"]; ok {
+		t.Fatal("state of IOST should be dropped")
+	}
+	if len(ctx.Notifiers) == 0 {
+		t.Fatal("notifiers not swapped")
+	}
+
+	// a stream started before the reload still push IOST
+	errc := make(chan error, 4)
+	Process(ctx, []feixiaohao.CoinPriceMeta{{Platform: "Binance", CoinType: "IOST", Price: "0.02 USDT", Percent: "1.00%"}}, errc)
+	if _, ok := ctx.LastRecord["IOST"]; ok {
+		t.Fatal("records of unwatched coins should be dropped")
+	}
+
+	config.Rules = append(config.Rules, ctx.Rules...)
+	config.Notifiers = []string{"pager"}
+	if _, err := NewReload(config); err == nil {
+		t.Fatal("unknown notifier should fail validation")
+	}
+
+	config.Notifiers = nil
+	config.Rules = []rule.Rule{
+		{Name: "up", Condition: rule.PercentAbove, Value: 3},
+		{Name: "up", Condition: rule.PercentAbove, Value: 5},
+	}
+	if _, err := NewReload(config); err == nil || !strings.Contains(err.Error(), "up") {
+		t.Fatal("duplicate rule names should fail validation: ", err)
+	}
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte("highpricepercent: 3\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan struct{}, 1)
+	quit := make(chan struct{})
+	defer close(quit)
+	go WatchConfigFile(path, 10*time.Millisecond, changed, quit)
+
+	time.Sleep(30 * time.Millisecond)
+	if err := ioutil.WriteFile(path, []byte("highpricepercent: 5.0\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("change not seen")
+	}
+
+	// a new drop-in is a change
+	if err := os.Mkdir(filepath.Join(dir, common.DropInDir), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, common.DropInDir, "extra.yaml"), []byte("lowpricepercent: -5\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("drop-in not seen")
+	}
+}
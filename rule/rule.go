@@ -0,0 +1,287 @@
+// Package rule decide when a coin price record should trigger an alert
+package rule
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/smileboywtu/CoinNotify/price"
+)
+
+// Condition types
+const (
+	// First fire when the coin never notified before
+	First = "first"
+
+	PercentAbove = "percent_above"
+	PercentBelow = "percent_below"
+
+	// Change compare the percent with the one of the previous round
+	Change = "change"
+
+	// ChangeSinceAlert compare the percent with the one of the last alert
+	ChangeSinceAlert = "change_since_alert"
+
+	PriceAbove = "price_above"
+	PriceBelow = "price_below"
+
+	// Crosses fire when the price moves over value in either direction
+	Crosses = "crosses"
+
+	// CrossAbove and CrossBelow fire once when the price reach value,
+	// and again only after the price went back to the other side
+	CrossAbove = "cross_above"
+	CrossBelow = "cross_below"
+
+	// VolumeAbove and VolumeBelow compare the 24h trade volume
+	VolumeAbove = "volume_above"
+	VolumeBelow = "volume_below"
+
+	MarketCapAbove = "marketcap_above"
+	MarketCapBelow = "marketcap_below"
+)
+
+var conditions = []string{First, PercentAbove, PercentBelow, Change, ChangeSinceAlert, PriceAbove, PriceBelow, Crosses, CrossAbove, CrossBelow, VolumeAbove, VolumeBelow, MarketCapAbove, MarketCapBelow}
+
+// Rule is one alert rule from config
+type Rule struct {
+	Name string `yaml:"name"`
+
+	// Coins select the coins, empty or "*" match every coin
+	Coins []string `yaml:"coins"`
+
+	Condition string  `yaml:"condition"`
+	Value     float64 `yaml:"value"`
+	// Currency limit the price, volume and market cap conditions to
+	// values in this currency
+	Currency string `yaml:"currency"`
+
+	// Cooldown is the seconds before the rule fire again, since the last
+	// alert of this rule for the coin when named, else since the last alert
+	// of the coin like the default rules
+	Cooldown int64 `yaml:"cooldown"`
+
+	// Channels is the notifier names, empty means the default notifiers
+	Channels []string `yaml:"channels"`
+}
+
+// State is what the rules know about one coin when a new record arrive
+type State struct {
+	Now int64
+
+	Percent float32
+	Price    float64
+	Currency string
+	// HasPrice is false when the price can not be parsed
+	HasPrice bool
+
+	// LastPercent is the percent of the previous round
+	LastPercent float32
+	// LastPrice is the price of the previous round in the same currency
+	LastPrice    float64
+	HasLastPrice bool
+
+	// AlertPercent is the percent when the coin notified last time
+	AlertPercent float32
+	// LastNotify is the unix time of the last alert, 0 for never
+	LastNotify int64
+	// RuleNotify is the unix time of the last alert of each rule by Key
+	RuleNotify map[string]int64
+
+	// Volume and MarketCap are nil when the source does not show them
+	Volume    *price.Value
+	MarketCap *price.Value
+}
+
+// Defaults build the rules that match the fixed high/low/amplitude behavior
+func Defaults(high, low, amplitude float32, period int64) []Rule {
+	return []Rule{
+		{Condition: First},
+		{Condition: PercentAbove, Value: float64(high), Cooldown: period},
+		{Condition: PercentBelow, Value: float64(low), Cooldown: period},
+		{Condition: Change, Value: float64(amplitude)},
+	}
+}
+
+// PriceLevels build the rules that fire once when the price cross above or below the level
+func PriceLevels(above, below *price.Value) []Rule {
+	rules := make([]Rule, 0, 2)
+	if above != nil {
+		rules = append(rules, Rule{Condition: CrossAbove, Value: above.Amount, Currency: above.Currency})
+	}
+	if below != nil {
+		rules = append(rules, Rule{Condition: CrossBelow, Value: below.Amount, Currency: below.Currency})
+	}
+	return rules
+}
+
+// Validate check the condition type and the channel list
+func (r Rule) Validate() error {
+	known := false
+	for _, condition := range conditions {
+		if r.Condition == condition {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("rule %s: unknown condition %q, use one of %s", r.Name, r.Condition, strings.Join(conditions, ", "))
+	}
+	if (r.Condition == Change || r.Condition == ChangeSinceAlert) && r.Value < 0 {
+		return fmt.Errorf("rule %s: change value should not be negative", r.Name)
+	}
+	if r.Cooldown < 0 {
+		return fmt.Errorf("rule %s: cooldown should not be negative", r.Name)
+	}
+	return nil
+}
+
+// Match tell if the rule select coin
+func (r Rule) Match(coin string) bool {
+	if len(r.Coins) == 0 {
+		return true
+	}
+	for _, selector := range r.Coins {
+		if selector == "*" || strings.EqualFold(selector, coin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check tell if the condition holds and the cooldown passed
+func (r Rule) Check(state State) bool {
+	last := state.LastNotify
+	if r.Name != "" {
+		last = state.RuleNotify[r.Key()]
+	}
+	if r.Cooldown > 0 && last > 0 && state.Now-last < r.Cooldown {
+		return false
+	}
+
+	switch r.Condition {
+	case PriceAbove, PriceBelow, Crosses, CrossAbove, CrossBelow:
+		if !state.HasPrice {
+			return false
+		}
+		if !(price.Value{Currency: r.Currency}).Comparable(price.Value{Currency: state.Currency}) {
+			return false
+		}
+	case VolumeAbove, VolumeBelow:
+		if !r.comparable(state.Volume) {
+			return false
+		}
+	case MarketCapAbove, MarketCapBelow:
+		if !r.comparable(state.MarketCap) {
+			return false
+		}
+	}
+
+	switch r.Condition {
+	case First:
+		return state.LastNotify == 0
+	case PercentAbove:
+		return float64(state.Percent) >= r.Value
+	case PercentBelow:
+		return float64(state.Percent) <= r.Value
+	case Change:
+		return math.Abs(float64(state.Percent-state.LastPercent)) >= r.Value
+	case ChangeSinceAlert:
+		return math.Abs(float64(state.Percent-state.AlertPercent)) >= r.Value
+	case PriceAbove:
+		return state.Price >= r.Value
+	case PriceBelow:
+		return state.Price <= r.Value
+	case Crosses:
+		if !state.HasLastPrice {
+			return false
+		}
+		return (state.LastPrice < r.Value && state.Price >= r.Value) ||
+			(state.LastPrice > r.Value && state.Price <= r.Value)
+	case CrossAbove:
+		// without a previous price the first record counts as a crossing
+		return state.Price >= r.Value && (!state.HasLastPrice || state.LastPrice < r.Value)
+	case CrossBelow:
+		return state.Price <= r.Value && (!state.HasLastPrice || state.LastPrice > r.Value)
+	case VolumeAbove:
+		return state.Volume.Amount >= r.Value
+	case VolumeBelow:
+		return state.Volume.Amount <= r.Value
+	case MarketCapAbove:
+		return state.MarketCap.Amount >= r.Value
+	case MarketCapBelow:
+		return state.MarketCap.Amount <= r.Value
+	}
+	return false
+}
+
+// Key identify the rule in the cooldown state, the name when set or
+// else the condition and its level
+func (r Rule) Key() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("%s:%v%s", r.Condition, r.Value, r.Currency)
+}
+
+// comparable tell if value is known and in the currency of the rule
+func (r Rule) comparable(value *price.Value) bool {
+	return value != nil && (price.Value{Currency: r.Currency}).Comparable(*value)
+}
+
+// Reason describe why the rule fired
+func (r Rule) Reason() string {
+	var reason string
+	switch r.Condition {
+	case First:
+		reason = "first notify"
+	case PercentAbove:
+		reason = fmt.Sprintf("percent above %.2f%%", r.Value)
+	case PercentBelow:
+		reason = fmt.Sprintf("percent below %.2f%%", r.Value)
+	case Change:
+		reason = fmt.Sprintf("amplitude over %.2f%%", r.Value)
+	case ChangeSinceAlert:
+		reason = fmt.Sprintf("change since last alert over %.2f%%", r.Value)
+	case PriceAbove:
+		reason = fmt.Sprintf("price above %s", r.level())
+	case PriceBelow:
+		reason = fmt.Sprintf("price below %s", r.level())
+	case Crosses:
+		reason = fmt.Sprintf("price crosses %s", r.level())
+	case CrossAbove:
+		reason = fmt.Sprintf("price crossed above %s", r.level())
+	case CrossBelow:
+		reason = fmt.Sprintf("price crossed below %s", r.level())
+	case VolumeAbove:
+		reason = fmt.Sprintf("volume above %s", r.level())
+	case VolumeBelow:
+		reason = fmt.Sprintf("volume below %s", r.level())
+	case MarketCapAbove:
+		reason = fmt.Sprintf("market cap above %s", r.level())
+	case MarketCapBelow:
+		reason = fmt.Sprintf("market cap below %s", r.level())
+	default:
+		reason = r.Condition
+	}
+	if r.Name != "" {
+		return r.Name + ": " + reason
+	}
+	return reason
+}
+
+func (r Rule) level() string {
+	return price.Value{Amount: r.Value, Currency: r.Currency}.String()
+}
+
+// Evaluate return the rules that select coin and fire on state
+func Evaluate(rules []Rule, coin string, state State) []Rule {
+	matched := make([]Rule, 0)
+	for _, r := range rules {
+		if r.Match(coin) && r.Check(state) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
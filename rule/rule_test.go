@@ -0,0 +1,82 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/smileboywtu/CoinNotify/price"
+)
+
+func TestCheck(t *testing.T) {
+	now := int64(100000)
+	cases := []struct {
+		name  string
+		rule  Rule
+		state State
+		fire  bool
+	}{
+		{"first", Rule{Condition: First}, State{Now: now}, true},
+		{"not first", Rule{Condition: First}, State{Now: now, LastNotify: now - 10}, false},
+		{"percent above", Rule{Condition: PercentAbove, Value: 3}, State{Percent: 3.5}, true},
+		{"percent not above", Rule{Condition: PercentAbove, Value: 3}, State{Percent: 2.5}, false},
+		{"percent below", Rule{Condition: PercentBelow, Value: -2}, State{Percent: -2.5}, true},
+		{"cooldown", Rule{Condition: PercentAbove, Value: 3, Cooldown: 3600}, State{Now: now, Percent: 5, LastNotify: now - 60}, false},
+		{"cooldown passed", Rule{Condition: PercentAbove, Value: 3, Cooldown: 3600}, State{Now: now, Percent: 5, LastNotify: now - 3600}, true},
+		// an amplitude alert reset the cooldown of the default rules
+		{"cooldown shared", Rule{Condition: PercentAbove, Value: 3, Cooldown: 3600}, State{Now: now, Percent: 5, LastNotify: now - 60, RuleNotify: map[string]int64{"change:1": now - 60}}, false},
+		{"named cooldown", Rule{Name: "up", Condition: PercentAbove, Value: 3, Cooldown: 3600}, State{Now: now, Percent: 5, LastNotify: now - 60, RuleNotify: map[string]int64{"up": now - 60}}, false},
+		{"other rule alerted", Rule{Name: "up", Condition: PercentAbove, Value: 3, Cooldown: 3600}, State{Now: now, Percent: 5, LastNotify: now - 60, RuleNotify: map[string]int64{"cross": now - 60}}, true},
+		{"change", Rule{Condition: Change, Value: 1}, State{Percent: 3.2, LastPercent: 5.2}, true},
+		{"small change", Rule{Condition: Change, Value: 1}, State{Percent: 3.2, LastPercent: 3.5}, false},
+		{"change since alert", Rule{Condition: ChangeSinceAlert, Value: 2}, State{Percent: 5, LastPercent: 4.5, AlertPercent: 2}, true},
+		{"price above", Rule{Condition: PriceAbove, Value: 0.5}, State{Price: 0.52, HasPrice: true}, true},
+		{"price unknown", Rule{Condition: PriceAbove, Value: 0.5}, State{}, false},
+		{"price below", Rule{Condition: PriceBelow, Value: 0.3}, State{Price: 0.29, HasPrice: true}, true},
+		{"crosses up", Rule{Condition: Crosses, Value: 0.5}, State{Price: 0.51, HasPrice: true, LastPrice: 0.49, HasLastPrice: true}, true},
+		{"crosses down", Rule{Condition: Crosses, Value: 0.5}, State{Price: 0.49, HasPrice: true, LastPrice: 0.51, HasLastPrice: true}, true},
+		{"stays above", Rule{Condition: Crosses, Value: 0.5}, State{Price: 0.52, HasPrice: true, LastPrice: 0.51, HasLastPrice: true}, false},
+		{"cross above first record", Rule{Condition: CrossAbove, Value: 0.5}, State{Price: 0.52, HasPrice: true}, true},
+		{"cross above", Rule{Condition: CrossAbove, Value: 0.5}, State{Price: 0.52, HasPrice: true, LastPrice: 0.49, HasLastPrice: true}, true},
+		{"already above", Rule{Condition: CrossAbove, Value: 0.5}, State{Price: 0.53, HasPrice: true, LastPrice: 0.52, HasLastPrice: true}, false},
+		{"cross below", Rule{Condition: CrossBelow, Value: 0.3}, State{Price: 0.29, HasPrice: true, LastPrice: 0.31, HasLastPrice: true}, true},
+		{"already below", Rule{Condition: CrossBelow, Value: 0.3}, State{Price: 0.28, HasPrice: true, LastPrice: 0.29, HasLastPrice: true}, false},
+		{"other currency", Rule{Condition: PriceAbove, Value: 0.5, Currency: "USD"}, State{Price: 3, Currency: "CNY", HasPrice: true}, false},
+		{"same currency", Rule{Condition: PriceAbove, Value: 0.5, Currency: "CNY"}, State{Price: 3, Currency: "CNY", HasPrice: true}, true},
+		{"volume above", Rule{Condition: VolumeAbove, Value: 1e8}, State{Volume: &price.Value{Amount: 1.23e8, Currency: "CNY"}}, true},
+		{"volume unknown", Rule{Condition: VolumeAbove, Value: 1e8}, State{}, false},
+		{"volume other currency", Rule{Condition: VolumeBelow, Value: 1e8, Currency: "USD"}, State{Volume: &price.Value{Amount: 100, Currency: "CNY"}}, false},
+		{"market cap below", Rule{Condition: MarketCapBelow, Value: 1e9}, State{MarketCap: &price.Value{Amount: 6.51e8, Currency: "CNY"}}, true},
+		{"market cap not above", Rule{Condition: MarketCapAbove, Value: 1e9}, State{MarketCap: &price.Value{Amount: 6.51e8}}, false},
+	}
+
+	for _, c := range cases {
+		if fire := c.rule.Check(c.state); fire != c.fire {
+			t.Errorf("%s: expect %v, got %v", c.name, c.fire, fire)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	rules := []Rule{
+		{Name: "cmt", Coins: []string{"cmt"}, Condition: PercentAbove, Value: 5, Channels: []string{"telegram"}},
+		{Coins: []string{"*"}, Condition: PercentAbove, Value: 3},
+	}
+
+	matched := Evaluate(rules, "CMT", State{Percent: 6})
+	if len(matched) != 2 || matched[0].Reason() != "cmt: percent above 5.00%" {
+		t.Fatal("CMT should match both rules: ", matched)
+	}
+	if matched := Evaluate(rules, "IOST", State{Percent: 6}); len(matched) != 1 {
+		t.Fatal("IOST should match the wildcard rule only: ", matched)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Rule{Condition: "percent_over"}).Validate(); err == nil {
+		t.Fatal("unknown condition should fail")
+	}
+	for _, r := range Defaults(3, -2, 1, 3600) {
+		if err := r.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yudai/gotty/pkg/homedir"
+	"github.com/smileboywtu/CoinNotify/binance"
+	"github.com/smileboywtu/CoinNotify/coingecko"
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+	"github.com/smileboywtu/CoinNotify/notify"
+	"github.com/smileboywtu/CoinNotify/source"
+)
+
+// NewPriceSource create the price source selected by config.Source, or the
+// consensus of config.Sources when several are listed, or a failover over
+// them when aggregate is failover. The returned quit
+// channel stop the background work of the source and is nil when there is
+// nothing to stop
+func NewPriceSource(config *AppConfigOpt) (source.PriceSource, chan struct{}, error) {
+	if len(config.Sources) == 0 {
+		pricesource, quit, err := newSource(config.Source, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		// alone the source can not fail over, a bad login should stop the start
+		if fxh, ok := pricesource.(*feixiaohao.Source); ok {
+			if _, err := fxh.Session.Cookies(); err != nil {
+				quit <- struct{}{}
+				return nil, nil, err
+			}
+		}
+		return pricesource, quit, nil
+	}
+
+	if config.SourceMode == "stream" {
+		return nil, nil, fmt.Errorf("stream mode can not be used with several sources")
+	}
+
+	// the per coin failover orders may name more sources
+	names := sourceNames(config)
+	orders := make(map[string][]string)
+	if config.Aggregate == source.FailoverMethod {
+		orders = config.CoinTypes.SourceOrders()
+	}
+
+	sources := make([]source.PriceSource, 0, len(names))
+	byname := make(map[string]source.PriceSource, len(names))
+	quits := make([]chan struct{}, 0)
+	for _, name := range names {
+		pricesource, quit, err := newSource(name, config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("source %s: %s", name, err)
+		}
+		if interval := sourceInterval(name, config); interval > PollInterval(config) {
+			pricesource = source.NewThrottle(pricesource, interval)
+		}
+		sources = append(sources, pricesource)
+		byname[name] = pricesource
+		if quit != nil {
+			quits = append(quits, quit)
+		}
+	}
+
+	if config.Aggregate == source.FailoverMethod {
+		failover, err := source.NewFailover(byname, config.Sources, orders, source.DefaultHealthPolicy, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		return failover, fanoutQuit(quits), nil
+	}
+
+	aggregator, err := source.NewAggregator(sources, config.Aggregate, config.Tolerance)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aggregator, fanoutQuit(quits), nil
+}
+
+// sourceNames return the sources config read from, the per coin failover
+// orders included
+func sourceNames(config *AppConfigOpt) []string {
+	if len(config.Sources) == 0 {
+		return []string{config.Source}
+	}
+	names := append([]string{}, config.Sources...)
+	if config.Aggregate == source.FailoverMethod {
+		for _, order := range config.CoinTypes.SourceOrders() {
+			for _, name := range order {
+				if !feixiaohao.HasSymbol(names, name) {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// sourceInterval is the time between two fetches of the named source
+func sourceInterval(name string, config *AppConfigOpt) time.Duration {
+	switch name {
+	case "binance":
+		return config.ExchangeInterval
+	case "coingecko":
+		return config.AggregatorInterval
+	}
+	return config.FeixiaohaoInterval
+}
+
+// PollInterval is the task tick, the interval of the fastest source. The
+// slower ones are throttled to their own interval
+func PollInterval(config *AppConfigOpt) time.Duration {
+	var interval time.Duration
+	for _, name := range sourceNames(config) {
+		if each := sourceInterval(name, config); interval == 0 || each < interval {
+			interval = each
+		}
+	}
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	return interval
+}
+
+// fanoutQuit return one quit channel that forward to every channel in quits
+func fanoutQuit(quits []chan struct{}) chan struct{} {
+	if len(quits) == 0 {
+		return nil
+	}
+	quit := make(chan struct{})
+	go func() {
+		<-quit
+		for _, q := range quits {
+			q <- struct{}{}
+		}
+	}()
+	return quit
+}
+
+// SourceSwitchReporter log every source switch of a failover and send the
+// switches after the first pick to the default notifiers when notify is set
+func SourceSwitchReporter(ctx *TaskContext, notifyswitch bool, errc chan error) source.SwitchFunc {
+	return func(coin, from, to, reason string) {
+		if from == "" {
+			fmt.Printf("source of %s: %s\n", coin, to)
+			return
+		}
+		fmt.Printf("source of %s switched from %s to %s: %s\n", coin, from, to, reason)
+		if !notifyswitch {
+			return
+		}
+
+		alert := notify.Alert{
+			Kind:     notify.KindWarning,
+			CoinType: coin,
+			Platform: to,
+			Reason:   fmt.Sprintf("price source switched from %s to %s, %s", from, to, reason),
+		}
+		notifiers := ctx.notifiers()
+		go func() {
+			if err := notify.Dispatch(notifiers, alert); err != nil {
+				errc <- err
+			}
+		}()
+	}
+}
+
+func newSource(name string, config *AppConfigOpt) (source.PriceSource, chan struct{}, error) {
+	switch name {
+	case "", "feixiaohao":
+		loginmeta := feixiaohao.UserLoginMeta{
+			UserID:     config.UserName,
+			PassWD:     config.PassWD,
+			IsRemember: false,
+		}
+		sessionfile := config.SessionFile
+		if sessionfile != "" {
+			sessionfile = homedir.Expand(sessionfile)
+		}
+		// the session login on the first fetch, so a feixiaohao outage at
+		// start only mark the source unhealthy in a failover
+		session := feixiaohao.NewSession(loginmeta, sessionfile)
+
+		// start renew task
+		quit := RenewSession(session)
+		return feixiaohao.NewSource(session), quit, nil
+	case "binance":
+		if config.SourceMode == "stream" {
+			return binance.NewStreamSource(binance.StreamOpt{
+				URL:      config.StreamURL,
+				Platform: config.ExchangeName,
+				Quotes:   config.ExchangeQuotes,
+			}), nil, nil
+		}
+		return binance.NewSource(binance.BinanceOpt{
+			BaseURL:  config.ExchangeURL,
+			Platform: config.ExchangeName,
+			Quotes:   config.ExchangeQuotes,
+		}), nil, nil
+	case "coingecko":
+		return coingecko.NewSource(coingecko.CoinGeckoOpt{
+			BaseURL:    config.AggregatorURL,
+			VsCurrency: config.AggregatorCurrency,
+			IDs:        config.AggregatorIDs,
+			APIKey:     config.AggregatorKey,
+		}), nil, nil
+	}
+	return nil, nil, fmt.Errorf("unknown price source: %s", name)
+}
@@ -0,0 +1,259 @@
+package source
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+	"github.com/smileboywtu/CoinNotify/price"
+)
+
+// Aggregate methods
+const (
+	Median   = "median"
+	Weighted = "weighted"
+
+	// FailoverMethod read each coin from one source, see Failover
+	FailoverMethod = "failover"
+)
+
+// ConsensusPlatform is the platform of an aggregated record
+const ConsensusPlatform = "consensus"
+
+// Aggregator watch every coin through several sources and combine their
+// records into one consensus record, so a single bad tick can not alert
+type Aggregator struct {
+	Sources []PriceSource
+
+	// Method is Median or Weighted, weighted by volume
+	Method string
+
+	// Tolerance is how far in percent a record may deviate from the
+	// median before it is dropped, 0 keep every record
+	Tolerance float64
+}
+
+func NewAggregator(sources []PriceSource, method string, tolerance float64) (*Aggregator, error) {
+	if method == "" {
+		method = Median
+	}
+	if method != Median && method != Weighted {
+		return nil, fmt.Errorf("unknown aggregate method: %s, use median or weighted", method)
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("aggregate need at least one source")
+	}
+	return &Aggregator{Sources: sources, Method: method, Tolerance: tolerance}, nil
+}
+
+func (a *Aggregator) Name() string {
+	names := make([]string, 0, len(a.Sources))
+	for _, source := range a.Sources {
+		names = append(names, source.Name())
+	}
+	return fmt.Sprintf("%s(%s)", a.Method, strings.Join(names, ","))
+}
+
+// Fetch query every source at once, a failed source is reported in the
+// error as a *SourceError while the others still make the consensus
+func (a *Aggregator) Fetch(filter feixiaohao.CoinFilter) ([]feixiaohao.CoinPriceMeta, error) {
+	results := make([][]feixiaohao.CoinPriceMeta, len(a.Sources))
+	errs := make([]error, len(a.Sources))
+
+	var wait sync.WaitGroup
+	for i, source := range a.Sources {
+		wait.Add(1)
+		go func(i int, source PriceSource) {
+			defer wait.Done()
+			results[i], errs[i] = source.Fetch(filter)
+		}(i, source)
+	}
+	wait.Wait()
+
+	var sourceErrs []*SourceError
+	failed := 0
+	coins := make(map[string][]feixiaohao.CoinPriceMeta)
+	for i, metas := range results {
+		if errs[i] != nil {
+			sourceErrs = append(sourceErrs, &SourceError{Source: a.Sources[i].Name(), Err: errs[i]})
+			if !feixiaohao.Partial(errs[i]) {
+				failed++
+			}
+		}
+		for _, meta := range metas {
+			coin := strings.ToUpper(meta.CoinType)
+			coins[coin] = append(coins[coin], meta)
+		}
+	}
+	if failed == len(a.Sources) {
+		var result error
+		for _, err := range sourceErrs {
+			result = multierror.Append(result, err)
+		}
+		return nil, result
+	}
+
+	// the round only fail when a coin get no consensus, a coin no source
+	// has is missing like from a watchlist unless a source is down
+	var consensusErrs []error
+	consensus := make([]feixiaohao.CoinPriceMeta, 0, len(coins))
+	for _, coin := range filter.CoinType {
+		records, ok := coins[strings.ToUpper(coin)]
+		if !ok {
+			if failed > 0 {
+				consensusErrs = append(consensusErrs, fmt.Errorf("%s has no record", coin))
+			}
+			continue
+		}
+		meta, err := Consensus(records, a.Method, a.Tolerance)
+		if err != nil {
+			consensusErrs = append(consensusErrs, err)
+			continue
+		}
+		consensus = append(consensus, meta)
+	}
+
+	var result error
+	for _, err := range sourceErrs {
+		err.Degraded = len(consensusErrs) == 0
+		result = multierror.Append(result, err)
+	}
+	for _, err := range consensusErrs {
+		result = multierror.Append(result, err)
+	}
+	return consensus, result
+}
+
+type record struct {
+	meta    feixiaohao.CoinPriceMeta
+	price   price.Value
+	percent float64
+	volume  price.Value
+}
+
+// Consensus combine the records of one coin, records in the currency most
+// sources use are checked on price, the others only on the 24h percent.
+// Pegged currencies like USDT and USD count as one. Weighted use the volume
+// only when every record give it in the same currency, else the median
+func Consensus(metas []feixiaohao.CoinPriceMeta, method string, tolerance float64) (feixiaohao.CoinPriceMeta, error) {
+	var coin string
+	records := make([]record, 0, len(metas))
+	currencies := make(map[string]int)
+	for _, meta := range metas {
+		coin = meta.CoinType
+		value, err := price.Parse(meta.Price)
+		if err != nil {
+			continue
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(meta.Percent), "%"), 64)
+		if err != nil {
+			continue
+		}
+		value.Currency = price.Canonical(value.Currency)
+		r := record{meta: meta, price: value, percent: percent}
+		if volume, err := price.Parse(meta.Volume); err == nil {
+			volume.Currency = price.Canonical(volume.Currency)
+			r.volume = volume
+		}
+		records = append(records, r)
+		currencies[value.Currency]++
+	}
+	if len(records) == 0 {
+		return feixiaohao.CoinPriceMeta{}, fmt.Errorf("%s has no usable record", coin)
+	}
+
+	// the reference currency is the one most records use, first seen on tie
+	reference := records[0].price.Currency
+	for _, r := range records {
+		if currencies[r.price.Currency] > currencies[reference] {
+			reference = r.price.Currency
+		}
+	}
+
+	var prices, percents []float64
+	for _, r := range records {
+		percents = append(percents, r.percent)
+		if r.price.Currency == reference {
+			prices = append(prices, r.price.Amount)
+		}
+	}
+	medianPrice, medianPercent := median(prices), median(percents)
+
+	agreed := make([]record, 0, len(records))
+	for _, r := range records {
+		if tolerance > 0 {
+			if r.price.Currency == reference {
+				if medianPrice != 0 && math.Abs(r.price.Amount-medianPrice)/math.Abs(medianPrice)*100 > tolerance {
+					continue
+				}
+			} else if math.Abs(r.percent-medianPercent) > tolerance {
+				continue
+			}
+		}
+		agreed = append(agreed, r)
+	}
+
+	prices, percents = prices[:0], percents[:0]
+	var priceWeights, percentWeights []price.Value
+	platforms := make([]string, 0, len(agreed))
+	for _, r := range agreed {
+		platforms = append(platforms, r.meta.Platform)
+		percents = append(percents, r.percent)
+		percentWeights = append(percentWeights, r.volume)
+		if r.price.Currency == reference {
+			prices = append(prices, r.price.Amount)
+			priceWeights = append(priceWeights, r.volume)
+		}
+	}
+	if len(prices) == 0 {
+		return feixiaohao.CoinPriceMeta{}, fmt.Errorf("%s sources disagree on price", coin)
+	}
+
+	var consensusPrice, consensusPercent float64
+	if method == Weighted {
+		consensusPrice, consensusPercent = weighted(prices, priceWeights), weighted(percents, percentWeights)
+	} else {
+		consensusPrice, consensusPercent = median(prices), median(percents)
+	}
+
+	return feixiaohao.CoinPriceMeta{
+		Platform: ConsensusPlatform,
+		Price:    price.Value{Amount: consensusPrice, Currency: reference}.String(),
+		Percent:  fmt.Sprintf("%.2f%%", consensusPercent),
+		CoinType: coin,
+		Sources:  strings.Join(platforms, ","),
+	}, nil
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// weighted average values by volume, fall back to median when a volume is
+// missing or the volumes are in different currencies
+func weighted(values []float64, weights []price.Value) float64 {
+	var sum, total float64
+	for i, value := range values {
+		if weights[i].Amount <= 0 || weights[i].Currency != weights[0].Currency {
+			return median(values)
+		}
+		sum += value * weights[i].Amount
+		total += weights[i].Amount
+	}
+	return sum / total
+}
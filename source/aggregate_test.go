@@ -0,0 +1,131 @@
+package source
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+)
+
+type staticSource struct {
+	name  string
+	metas []feixiaohao.CoinPriceMeta
+	err   error
+}
+
+func (s staticSource) Name() string {
+	return s.name
+}
+
+func (s staticSource) Fetch(filter feixiaohao.CoinFilter) ([]feixiaohao.CoinPriceMeta, error) {
+	return s.metas, s.err
+}
+
+func TestConsensusOutlier(t *testing.T) {
+	metas := []feixiaohao.CoinPriceMeta{
+		{Platform: "A", CoinType: "CMT", Price: "0.50 USD", Percent: "5.0%"},
+		{Platform: "B", CoinType: "CMT", Price: "0.52 USD", Percent: "5.4%"},
+		// bad tick
+		{Platform: "C", CoinType: "CMT", Price: "0.90 USD", Percent: "80.0%"},
+		// other currency is checked on percent only
+		{Platform: "D", CoinType: "CMT", Price: "¥3.5", Percent: "5.2%"},
+	}
+
+	meta, err := Consensus(metas, Median, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Sources != "A,B,D" || meta.Price != "0.51 USD" || meta.Percent != "5.20%" || meta.Platform != ConsensusPlatform {
+		t.Fatal("bad consensus: ", meta)
+	}
+
+	// no tolerance keep every record
+	if meta, _ := Consensus(metas, Median, 0); meta.Sources != "A,B,C,D" {
+		t.Fatal("zero tolerance should keep the outlier: ", meta)
+	}
+}
+
+func TestConsensusWeighted(t *testing.T) {
+	metas := []feixiaohao.CoinPriceMeta{
+		{Platform: "A", CoinType: "BTC", Price: "100 USD", Percent: "1%", Volume: "3000 USD"},
+		{Platform: "B", CoinType: "BTC", Price: "104 USD", Percent: "3%", Volume: "1000 USD"},
+	}
+	meta, err := Consensus(metas, Weighted, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Price != "101 USD" || meta.Percent != "1.50%" {
+		t.Fatal("bad weighted consensus: ", meta)
+	}
+
+	// volumes in different currencies can not weight each other
+	metas[1].Volume = "1000 BTC"
+	if meta, _ := Consensus(metas, Weighted, 10); meta.Price != "102 USD" || meta.Percent != "2.00%" {
+		t.Fatal("mixed volume currencies should fall back to median: ", meta)
+	}
+}
+
+func TestConsensusPegged(t *testing.T) {
+	metas := []feixiaohao.CoinPriceMeta{
+		{Platform: "A", CoinType: "BTC", Price: "100 USDT", Percent: "1%"},
+		{Platform: "B", CoinType: "BTC", Price: "102 USD", Percent: "1%"},
+		// bad tick only caught when USDT and USD are compared on price
+		{Platform: "C", CoinType: "BTC", Price: "150 USDC", Percent: "1%"},
+	}
+	meta, err := Consensus(metas, Median, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Sources != "A,B" || meta.Price != "101 USD" {
+		t.Fatal("bad pegged consensus: ", meta)
+	}
+}
+
+func TestAggregatorFetch(t *testing.T) {
+	aggregator, err := NewAggregator([]PriceSource{
+		staticSource{name: "a", metas: []feixiaohao.CoinPriceMeta{{Platform: "A", CoinType: "CMT", Price: "0.50", Percent: "5%"}}},
+		staticSource{name: "b", err: errors.New("down")},
+		staticSource{name: "c", metas: []feixiaohao.CoinPriceMeta{{Platform: "C", CoinType: "cmt", Price: "0.52", Percent: "6%"}}},
+	}, Median, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metas, err := aggregator.Fetch(feixiaohao.CoinFilter{CoinType: []string{"CMT"}})
+	if err == nil {
+		t.Fatal("failed source should be reported")
+	}
+	if len(metas) != 1 || metas[0].Sources != "A,C" || metas[0].Price != "0.51" {
+		t.Fatal("bad aggregated records: ", metas)
+	}
+	merr, ok := err.(*multierror.Error)
+	if !ok || len(merr.Errors) != 1 {
+		t.Fatal("the source error should be kept as is: ", err)
+	}
+	if serr, ok := merr.Errors[0].(*SourceError); !ok || serr.Source != "b" || serr.Err.Error() != "down" {
+		t.Fatal("the source error should be kept as is: ", err)
+	}
+	if !feixiaohao.Partial(err) {
+		t.Fatal("a down source should not fail the round when every coin has a consensus: ", err)
+	}
+
+	// a coin only the down source could serve get no consensus
+	metas, err = aggregator.Fetch(feixiaohao.CoinFilter{CoinType: []string{"CMT", "IOST"}})
+	if len(metas) != 1 || err == nil || feixiaohao.Partial(err) {
+		t.Fatal("a coin without consensus should fail the round: ", metas, err)
+	}
+
+	layout, _ := NewAggregator([]PriceSource{
+		staticSource{name: "a", metas: []feixiaohao.CoinPriceMeta{{Platform: "A", CoinType: "CMT", Price: "0.50", Percent: "5%"}}},
+		staticSource{name: "b", err: &feixiaohao.LayoutError{Reason: "no table"}},
+	}, Median, 10)
+	if _, err := layout.Fetch(feixiaohao.CoinFilter{CoinType: []string{"CMT"}}); !feixiaohao.LayoutChanged(err) {
+		t.Fatal("the layout error of a source should still be seen: ", err)
+	}
+
+	if _, err := NewAggregator(nil, "mean", 0); err == nil {
+		t.Fatal("unknown method should fail")
+	}
+}
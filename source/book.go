@@ -0,0 +1,59 @@
+package source
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+)
+
+// PriceBook keep the latest record of every coin and platform, it is
+// safe for concurrent use
+type PriceBook struct {
+	lock    sync.RWMutex
+	records map[string]map[string]feixiaohao.CoinPriceMeta
+}
+
+func NewPriceBook() *PriceBook {
+	return &PriceBook{records: make(map[string]map[string]feixiaohao.CoinPriceMeta)}
+}
+
+// Update replace the record of meta.CoinType on meta.Platform
+func (b *PriceBook) Update(meta feixiaohao.CoinPriceMeta) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	platforms, ok := b.records[meta.CoinType]
+	if !ok {
+		platforms = make(map[string]feixiaohao.CoinPriceMeta)
+		b.records[meta.CoinType] = platforms
+	}
+	platforms[meta.Platform] = meta
+}
+
+// Get return the record of coin on platform
+func (b *PriceBook) Get(coin, platform string) (feixiaohao.CoinPriceMeta, bool) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	meta, ok := b.records[coin][platform]
+	return meta, ok
+}
+
+// Records return every record of the coins in filter, in filter order
+func (b *PriceBook) Records(filter feixiaohao.CoinFilter) []feixiaohao.CoinPriceMeta {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	metas := make([]feixiaohao.CoinPriceMeta, 0, len(filter.CoinType))
+	for _, coin := range filter.CoinType {
+		platforms := b.records[strings.ToUpper(coin)]
+		names := make([]string, 0, len(platforms))
+		for name := range platforms {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			metas = append(metas, platforms[name])
+		}
+	}
+	return metas
+}
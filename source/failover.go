@@ -0,0 +1,333 @@
+package source
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+)
+
+// Health is the running score of one source
+type Health struct {
+	// ErrorRate is the moving average of failed fetches, 0 to 1
+	ErrorRate float64
+	// Latency is the moving average of fetch time
+	Latency time.Duration
+
+	ConsecutiveFailures int
+	LastAttempt         time.Time
+	LastSuccess         time.Time
+	LastError           error
+}
+
+// HealthPolicy decide when a source is healthy
+type HealthPolicy struct {
+	MaxErrorRate float64
+	MaxLatency   time.Duration
+	// MaxStaleness is the longest time without a successful fetch
+	MaxStaleness time.Duration
+	// ProbeInterval is how often an unhealthy source is tried again
+	ProbeInterval time.Duration
+}
+
+var DefaultHealthPolicy = HealthPolicy{
+	MaxErrorRate:  0.5,
+	MaxLatency:    10 * time.Second,
+	MaxStaleness:  2 * time.Minute,
+	ProbeInterval: 30 * time.Second,
+}
+
+// healthAlpha is the weight of the newest fetch in the moving averages
+const healthAlpha = 0.3
+
+// Healthy tell if the source can serve, a never used source is healthy
+func (h Health) Healthy(policy HealthPolicy, now time.Time) bool {
+	if h.LastAttempt.IsZero() {
+		return true
+	}
+	if h.ConsecutiveFailures > 0 || h.ErrorRate > policy.MaxErrorRate {
+		return false
+	}
+	if policy.MaxLatency > 0 && h.Latency > policy.MaxLatency {
+		return false
+	}
+	if policy.MaxStaleness > 0 && now.Sub(h.LastSuccess) > policy.MaxStaleness {
+		return false
+	}
+	return true
+}
+
+func (h *Health) record(err error, latency time.Duration, now time.Time) {
+	failed := 0.0
+	if err != nil {
+		failed = 1.0
+	}
+	if h.LastAttempt.IsZero() {
+		h.ErrorRate, h.Latency = failed, latency
+	} else {
+		h.ErrorRate = healthAlpha*failed + (1-healthAlpha)*h.ErrorRate
+		h.Latency = time.Duration(healthAlpha*float64(latency) + (1-healthAlpha)*float64(h.Latency))
+	}
+	h.LastAttempt = now
+	h.LastError = err
+	if err != nil {
+		h.ConsecutiveFailures++
+		return
+	}
+	h.ConsecutiveFailures = 0
+	h.LastSuccess = now
+}
+
+// SwitchFunc is called when a coin move to another source, from is empty
+// for the first pick
+type SwitchFunc func(coin, from, to, reason string)
+
+// Failover read every coin from the first healthy source of its ordered
+// list, fall over to the next one on failure and fall back when the
+// preferred source recover
+type Failover struct {
+	Sources map[string]PriceSource
+
+	// Default is the source order of coins not in Orders
+	Default []string
+	Orders  map[string][]string
+
+	Policy   HealthPolicy
+	OnSwitch SwitchFunc
+
+	lock   sync.Mutex
+	health map[string]*Health
+	active map[string]string
+	now    func() time.Time
+}
+
+// NewFailover create a failover over sources, order is the default order
+// of the source names and orders the per coin orders
+func NewFailover(sources map[string]PriceSource, order []string, orders map[string][]string, policy HealthPolicy, onSwitch SwitchFunc) (*Failover, error) {
+	if len(order) == 0 {
+		return nil, fmt.Errorf("failover need at least one source")
+	}
+
+	failover := &Failover{
+		Sources:  sources,
+		Default:  order,
+		Orders:   make(map[string][]string, len(orders)),
+		Policy:   policy,
+		OnSwitch: onSwitch,
+		health:   make(map[string]*Health, len(sources)),
+		active:   make(map[string]string),
+		now:      time.Now,
+	}
+	for name := range sources {
+		failover.health[name] = &Health{}
+	}
+	for _, name := range order {
+		if _, ok := sources[name]; !ok {
+			return nil, fmt.Errorf("unknown failover source: %s", name)
+		}
+	}
+	for coin, coinorder := range orders {
+		for _, name := range coinorder {
+			if _, ok := sources[name]; !ok {
+				return nil, fmt.Errorf("coin %s use unknown source %s", coin, name)
+			}
+		}
+		failover.Orders[strings.ToUpper(coin)] = coinorder
+	}
+	return failover, nil
+}
+
+func (f *Failover) Name() string {
+	return fmt.Sprintf("failover(%s)", strings.Join(f.Default, ","))
+}
+
+// Health return a copy of the score of every source
+func (f *Failover) Health() map[string]Health {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	health := make(map[string]Health, len(f.health))
+	for name, h := range f.health {
+		health[name] = *h
+	}
+	return health
+}
+
+// Active return the source serving coin
+func (f *Failover) Active(coin string) string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.active[strings.ToUpper(coin)]
+}
+
+func (f *Failover) order(coin string) []string {
+	if order, ok := f.Orders[coin]; ok && len(order) > 0 {
+		return order
+	}
+	return f.Default
+}
+
+// eligible tell if a source may be tried, an unhealthy source is probed
+// again after the probe interval
+func (f *Failover) eligible(name string, now time.Time) bool {
+	h := f.health[name]
+	if h.Healthy(f.Policy, now) {
+		return true
+	}
+	return now.Sub(h.LastAttempt) >= f.Policy.ProbeInterval
+}
+
+func (f *Failover) Fetch(filter feixiaohao.CoinFilter) ([]feixiaohao.CoinPriceMeta, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	now := f.now()
+	var result error
+	var unserved []string
+
+	// next candidate index of every coin
+	pending := make(map[string]int, len(filter.CoinType))
+	names := make(map[string]string, len(filter.CoinType))
+	coins := make([]string, 0, len(filter.CoinType))
+	for _, coin := range filter.CoinType {
+		coins = append(coins, strings.ToUpper(coin))
+		pending[strings.ToUpper(coin)] = 0
+		names[strings.ToUpper(coin)] = coin
+	}
+	served := make(map[string]feixiaohao.CoinPriceMeta)
+	reasons := make(map[string]string)
+	tried := make(map[string]bool)
+	// errors of the sources that failed outright, reported when a coin
+	// they should serve get no record
+	failures := make(map[string]error)
+	failed := make(map[string]bool)
+
+	for len(pending) > 0 {
+		// group the pending coins by their next eligible source, in config
+		// order so the sources are asked in a stable order
+		groups := make(map[string][]string)
+		picks := make([]string, 0)
+		for _, coin := range coins {
+			next, ok := pending[coin]
+			if !ok {
+				continue
+			}
+			order := f.order(coin)
+			picked := ""
+			for i := next; i < len(order); i++ {
+				if f.eligible(order[i], now) && !tried[order[i]+"/"+coin] {
+					picked = order[i]
+					pending[coin] = i + 1
+					break
+				}
+			}
+			if picked == "" {
+				delete(pending, coin)
+				if reasons[coin] == "" {
+					reasons[coin], failed[coin] = "every source is unhealthy", true
+				}
+				unserved = append(unserved, coin)
+				continue
+			}
+			if _, ok := groups[picked]; !ok {
+				picks = append(picks, picked)
+			}
+			groups[picked] = append(groups[picked], coin)
+		}
+
+		for _, name := range picks {
+			group := groups[name]
+			sub := filter
+			sub.CoinType = make([]string, 0, len(group))
+			for _, coin := range group {
+				sub.CoinType = append(sub.CoinType, names[coin])
+				tried[name+"/"+coin] = true
+			}
+
+			// missing coins do not make the source unhealthy, the records
+			// of the others are used
+			start := f.now()
+			metas, err := f.Sources[name].Fetch(sub)
+			partial := feixiaohao.Partial(err)
+			if partial {
+				f.health[name].record(nil, f.now().Sub(start), start)
+			} else {
+				f.health[name].record(err, f.now().Sub(start), start)
+			}
+			if err != nil && !partial {
+				failures[name] = &SourceError{Source: name, Err: err}
+				for _, coin := range group {
+					reasons[coin] = fmt.Sprintf("%s failed: %s", name, err)
+					failed[coin] = true
+				}
+				continue
+			}
+
+			for _, meta := range metas {
+				coin := strings.ToUpper(meta.CoinType)
+				if _, ok := pending[coin]; !ok || !tried[name+"/"+coin] {
+					continue
+				}
+				served[coin] = meta
+				delete(pending, coin)
+				f.switchTo(coin, name, reasons[coin])
+			}
+			for _, coin := range group {
+				if _, ok := pending[coin]; ok {
+					reasons[coin] = fmt.Sprintf("%s has no record of %s", name, coin)
+				}
+			}
+		}
+	}
+
+	// only the coins no source served are errors, the ones every source
+	// answered without are missing like from a watchlist
+	var missing []string
+	reported := make(map[string]bool)
+	for _, coin := range unserved {
+		if !failed[coin] {
+			missing = append(missing, names[coin])
+			continue
+		}
+		result = multierror.Append(result, fmt.Errorf("%s has no healthy source: %s", names[coin], reasons[coin]))
+		for _, name := range f.order(coin) {
+			if err, ok := failures[name]; ok && !reported[name] {
+				reported[name] = true
+				result = multierror.Append(result, err)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		result = multierror.Append(result, &feixiaohao.NotWatchedError{Coins: missing})
+	}
+
+	metas := make([]feixiaohao.CoinPriceMeta, 0, len(served))
+	for _, coin := range filter.CoinType {
+		if meta, ok := served[strings.ToUpper(coin)]; ok {
+			metas = append(metas, meta)
+		}
+	}
+	return metas, result
+}
+
+// switchTo record the source serving coin and report a change
+func (f *Failover) switchTo(coin, name, reason string) {
+	from := f.active[coin]
+	if from == name {
+		return
+	}
+	f.active[coin] = name
+	if reason == "" {
+		if from == "" {
+			reason = "first pick"
+		} else {
+			reason = "preferred source recovered"
+		}
+	}
+	if f.OnSwitch != nil {
+		f.OnSwitch(coin, from, name, reason)
+	}
+}
@@ -0,0 +1,181 @@
+package source
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+)
+
+type flakySource struct {
+	staticSource
+	down bool
+}
+
+func (s *flakySource) Fetch(filter feixiaohao.CoinFilter) ([]feixiaohao.CoinPriceMeta, error) {
+	if s.down {
+		return nil, errors.New("down")
+	}
+	metas := make([]feixiaohao.CoinPriceMeta, 0)
+	for _, meta := range s.metas {
+		if feixiaohao.HasSymbol(filter.CoinType, meta.CoinType) {
+			metas = append(metas, meta)
+		}
+	}
+	return metas, nil
+}
+
+func TestFailover(t *testing.T) {
+	primary := &flakySource{staticSource: staticSource{name: "primary", metas: []feixiaohao.CoinPriceMeta{
+		{Platform: "primary", CoinType: "CMT", Percent: "1%"},
+		{Platform: "primary", CoinType: "BTC", Percent: "1%"},
+	}}}
+	backup := &flakySource{staticSource: staticSource{name: "backup", metas: []feixiaohao.CoinPriceMeta{
+		{Platform: "backup", CoinType: "CMT", Percent: "2%"},
+		{Platform: "backup", CoinType: "BTC", Percent: "2%"},
+	}}}
+
+	switches := make([]string, 0)
+	failover, err := NewFailover(
+		map[string]PriceSource{"primary": primary, "backup": backup},
+		[]string{"primary", "backup"},
+		map[string][]string{"btc": {"backup", "primary"}},
+		DefaultHealthPolicy,
+		func(coin, from, to, reason string) {
+			switches = append(switches, coin+":"+from+">"+to)
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1000, 0)
+	failover.now = func() time.Time { return now }
+
+	filter := feixiaohao.CoinFilter{CoinType: []string{"CMT", "BTC"}}
+	platforms := func() string {
+		metas, _ := failover.Fetch(filter)
+		result := ""
+		for _, meta := range metas {
+			result += meta.CoinType + "@" + meta.Platform + " "
+		}
+		return result
+	}
+
+	if got := platforms(); got != "CMT@primary BTC@backup " {
+		t.Fatal("bad first round: ", got)
+	}
+
+	// primary down, CMT move to the backup in the same round
+	primary.down = true
+	now = now.Add(2 * time.Second)
+	if got := platforms(); got != "CMT@backup BTC@backup " {
+		t.Fatal("bad failover: ", got)
+	}
+	if failover.Health()["primary"].ConsecutiveFailures != 1 {
+		t.Fatal("primary failure not recorded: ", failover.Health()["primary"])
+	}
+
+	// recovered but not probed before the probe interval
+	primary.down = false
+	now = now.Add(2 * time.Second)
+	if got := platforms(); got != "CMT@backup BTC@backup " {
+		t.Fatal("unhealthy primary used before probe: ", got)
+	}
+
+	// probe succeed and CMT fail back
+	now = now.Add(DefaultHealthPolicy.ProbeInterval)
+	if got := platforms(); got != "CMT@primary BTC@backup " {
+		t.Fatal("bad failback: ", got)
+	}
+
+	want := []string{"CMT:>primary", "BTC:>backup", "CMT:primary>backup", "CMT:backup>primary"}
+	if len(switches) != len(want) {
+		t.Fatal("bad switches: ", switches)
+	}
+	for i := range want {
+		if switches[i] != want[i] {
+			t.Fatal("bad switches: ", switches)
+		}
+	}
+
+	// every source down
+	primary.down, backup.down = true, true
+	now = now.Add(2 * time.Second)
+	if metas, err := failover.Fetch(filter); err == nil || len(metas) != 0 || feixiaohao.Partial(err) {
+		t.Fatal("expect error when every source is down: ", metas, err)
+	}
+}
+
+// watchlistSource serve only its coins and report the others missing like
+// the feixiaohao watchlist
+type watchlistSource struct {
+	staticSource
+}
+
+func (s *watchlistSource) Fetch(filter feixiaohao.CoinFilter) ([]feixiaohao.CoinPriceMeta, error) {
+	metas := make([]feixiaohao.CoinPriceMeta, 0)
+	var missing []string
+	for _, coin := range filter.CoinType {
+		found := false
+		for _, meta := range s.metas {
+			if meta.CoinType == coin {
+				metas, found = append(metas, meta), true
+			}
+		}
+		if !found {
+			missing = append(missing, coin)
+		}
+	}
+	if len(missing) > 0 {
+		return metas, &feixiaohao.NotWatchedError{Coins: missing}
+	}
+	return metas, nil
+}
+
+func TestFailoverPartial(t *testing.T) {
+	primary := &watchlistSource{staticSource{name: "primary", metas: []feixiaohao.CoinPriceMeta{
+		{Platform: "primary", CoinType: "CMT", Percent: "1%"},
+	}}}
+	backup := &watchlistSource{staticSource{name: "backup", metas: []feixiaohao.CoinPriceMeta{
+		{Platform: "backup", CoinType: "BTC", Percent: "2%"},
+	}}}
+	failover, err := NewFailover(map[string]PriceSource{"primary": primary, "backup": backup}, []string{"primary", "backup"}, nil, DefaultHealthPolicy, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// BTC missing from the primary is served by the backup
+	metas, err := failover.Fetch(feixiaohao.CoinFilter{CoinType: []string{"CMT", "BTC"}})
+	if err != nil || len(metas) != 2 {
+		t.Fatal("every coin was served: ", metas, err)
+	}
+	if health := failover.Health()["primary"]; health.ConsecutiveFailures != 0 || health.ErrorRate != 0 {
+		t.Fatal("a missing coin should not make the source unhealthy: ", health)
+	}
+
+	// a coin no source has is only missing
+	metas, err = failover.Fetch(feixiaohao.CoinFilter{CoinType: []string{"CMT", "EOS"}})
+	if len(metas) != 1 || !feixiaohao.NotWatched(err) || !feixiaohao.Partial(err) {
+		t.Fatal("unknown coin should be reported missing: ", metas, err)
+	}
+}
+
+func TestHealthStale(t *testing.T) {
+	now := time.Unix(1000, 0)
+	health := Health{}
+	if !health.Healthy(DefaultHealthPolicy, now) {
+		t.Fatal("unused source should be healthy")
+	}
+	health.record(nil, time.Second, now)
+	if !health.Healthy(DefaultHealthPolicy, now) {
+		t.Fatal("source should be healthy after success")
+	}
+	if health.Healthy(DefaultHealthPolicy, now.Add(DefaultHealthPolicy.MaxStaleness+time.Second)) {
+		t.Fatal("stale source should be unhealthy")
+	}
+	health.record(nil, time.Minute, now)
+	if health.Healthy(DefaultHealthPolicy, now) {
+		t.Fatal("slow source should be unhealthy: ", health.Latency)
+	}
+}
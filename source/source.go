@@ -0,0 +1,71 @@
+// Package source defines the price data provider used by the notifier task
+package source
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+)
+
+// PriceSource fetch the current price of the coins selected by filter
+type PriceSource interface {
+	// Name return the provider name, used in logs
+	Name() string
+
+	// Fetch return one price record for every coin matched by filter
+	Fetch(filter feixiaohao.CoinFilter) ([]feixiaohao.CoinPriceMeta, error)
+}
+
+// StreamSource push records as prices change instead of being polled
+type StreamSource interface {
+	PriceSource
+
+	// Stream send a record to updates on every change of a coin in filter,
+	// it reconnect by itself and only return after quit is closed. Every
+	// lost connection is sent to errc as a *DisconnectError
+	Stream(filter feixiaohao.CoinFilter, updates chan<- feixiaohao.CoinPriceMeta, quit <-chan struct{}, errc chan<- error)
+
+	// Subscribe change the coins of a running stream, like after a reload
+	Subscribe(filter feixiaohao.CoinFilter)
+}
+
+// DisconnectError is a lost stream connection, the stream reconnect by
+// itself after Backoff
+type DisconnectError struct {
+	Source  string
+	Backoff time.Duration
+	Err     error
+}
+
+func (e *DisconnectError) Error() string {
+	return fmt.Sprintf("%s disconnected, reconnect in %s: %s", e.Source, e.Backoff, e.Err)
+}
+
+func (e *DisconnectError) Unwrap() error {
+	return e.Err
+}
+
+// SourceError is the error of one source among several, it keep the source
+// error as is so feixiaohao.Partial and LayoutChanged still see its type
+type SourceError struct {
+	Source string
+	Err    error
+
+	// Degraded mean the other sources still served every coin, the error
+	// does not fail the round
+	Degraded bool
+}
+
+func (e *SourceError) Error() string {
+	return e.Source + ": " + e.Err.Error()
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// Partial tell feixiaohao.Partial the records of the coins were read
+func (e *SourceError) Partial() bool {
+	return e.Degraded || feixiaohao.Partial(e.Err)
+}
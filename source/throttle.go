@@ -0,0 +1,98 @@
+package source
+
+import (
+	"sync"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+)
+
+// Throttle ask Source at most once per Interval whatever coins are asked, a
+// Fetch in between get the records of the last ask. Every coin asked since
+// the last ask is asked next time, so the changing coin groups of a
+// Failover are served too. A coin asked for the first time in between is
+// reported missing until the next ask, an error is returned only once
+type Throttle struct {
+	Source   PriceSource
+	Interval time.Duration
+
+	lock sync.Mutex
+	last time.Time
+	// asked is the coins asked since the last ask, all is set when a
+	// filter asked every coin
+	asked   []string
+	all     bool
+	records map[string][]feixiaohao.CoinPriceMeta
+	now     func() time.Time
+}
+
+func NewThrottle(source PriceSource, interval time.Duration) *Throttle {
+	return &Throttle{Source: source, Interval: interval, now: time.Now}
+}
+
+func (t *Throttle) Name() string {
+	return t.Source.Name()
+}
+
+func (t *Throttle) Fetch(filter feixiaohao.CoinFilter) ([]feixiaohao.CoinPriceMeta, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if len(filter.CoinType) == 0 {
+		t.all = true
+	}
+	for _, coin := range filter.CoinType {
+		if !feixiaohao.HasSymbol(t.asked, coin) {
+			t.asked = append(t.asked, coin)
+		}
+	}
+
+	now := t.now()
+	if !t.last.IsZero() && now.Sub(t.last) < t.Interval {
+		return t.pick(filter)
+	}
+
+	ask := filter
+	ask.CoinType = t.asked
+	if t.all {
+		ask.CoinType = nil
+	}
+	records, err := t.Source.Fetch(ask)
+	t.last, t.asked, t.all = now, nil, false
+	t.records = make(map[string][]feixiaohao.CoinPriceMeta, len(records))
+	for _, meta := range records {
+		coin := feixiaohao.NormalizeSymbol(meta.CoinType)
+		t.records[coin] = append(t.records[coin], meta)
+	}
+	if err != nil && !feixiaohao.Partial(err) {
+		metas, _ := t.pick(filter)
+		return metas, err
+	}
+	return t.pick(filter)
+}
+
+// pick return the cached records of filter, the coins without record are
+// reported missing
+func (t *Throttle) pick(filter feixiaohao.CoinFilter) ([]feixiaohao.CoinPriceMeta, error) {
+	metas := make([]feixiaohao.CoinPriceMeta, 0, len(filter.CoinType))
+	if len(filter.CoinType) == 0 {
+		for _, records := range t.records {
+			metas = append(metas, records...)
+		}
+		return metas, nil
+	}
+
+	var missing []string
+	for _, coin := range filter.CoinType {
+		records, ok := t.records[feixiaohao.NormalizeSymbol(coin)]
+		if !ok {
+			missing = append(missing, coin)
+			continue
+		}
+		metas = append(metas, records...)
+	}
+	if len(missing) > 0 {
+		return metas, &feixiaohao.NotWatchedError{Coins: missing}
+	}
+	return metas, nil
+}
@@ -0,0 +1,77 @@
+package source
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+)
+
+type countSource struct {
+	calls int
+	asked []string
+	err   error
+}
+
+func (c *countSource) Name() string {
+	return "count"
+}
+
+func (c *countSource) Fetch(filter feixiaohao.CoinFilter) ([]feixiaohao.CoinPriceMeta, error) {
+	c.calls++
+	c.asked = filter.CoinType
+	if c.err != nil {
+		return nil, c.err
+	}
+	metas := make([]feixiaohao.CoinPriceMeta, 0, len(filter.CoinType))
+	for _, coin := range filter.CoinType {
+		metas = append(metas, feixiaohao.CoinPriceMeta{CoinType: coin})
+	}
+	return metas, nil
+}
+
+func TestThrottle(t *testing.T) {
+	now := time.Unix(1000, 0)
+	counter := &countSource{}
+	throttle := NewThrottle(counter, 30*time.Second)
+	throttle.now = func() time.Time { return now }
+
+	for i, step := range []struct {
+		after   time.Duration
+		coins   []string
+		calls   int
+		records int
+		missing bool
+	}{
+		{0, []string{"BTC"}, 1, 1, false},
+		{10 * time.Second, []string{"BTC"}, 1, 1, false},
+		// a new coin wait for the next ask
+		{1 * time.Second, []string{"ETH"}, 1, 0, true},
+		{20 * time.Second, []string{"BTC"}, 2, 1, false},
+		{1 * time.Second, []string{"ETH"}, 2, 1, false},
+	} {
+		now = now.Add(step.after)
+		records, err := throttle.Fetch(feixiaohao.CoinFilter{CoinType: step.coins})
+		if len(records) != step.records || feixiaohao.NotWatched(err) != step.missing {
+			t.Fatal("step ", i, ": bad records ", records, err)
+		}
+		if counter.calls != step.calls {
+			t.Fatal("step ", i, ": source asked ", counter.calls, " times, want ", step.calls)
+		}
+	}
+	if len(counter.asked) != 2 {
+		t.Fatal("the coins asked in between should be asked together: ", counter.asked)
+	}
+
+	// an error is reported once
+	counter.err = errors.New("down")
+	now = now.Add(time.Minute)
+	if _, err := throttle.Fetch(feixiaohao.CoinFilter{CoinType: []string{"BTC"}}); err != counter.err {
+		t.Fatal("error should be returned: ", err)
+	}
+	now = now.Add(time.Second)
+	if _, err := throttle.Fetch(feixiaohao.CoinFilter{CoinType: []string{"BTC"}}); err == counter.err || counter.calls != 3 {
+		t.Fatal("error should be returned only once: ", err)
+	}
+}
@@ -0,0 +1,158 @@
+// Package store persist the notify state so a restart does not alert every coin again
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/price"
+)
+
+// Snapshot is the per coin notify state
+type Snapshot struct {
+	LastNotifyTime  map[string]int64       `json:"lastnotifytime"`
+	LastRecord      map[string]float32     `json:"lastrecord"`
+	LastAlertRecord map[string]float32     `json:"lastalertrecord"`
+	LastPrice       map[string]price.Value `json:"lastprice"`
+	MuteUntil       map[string]int64       `json:"muteuntil"`
+	// LastRuleNotify is the last alert time of each rule of a coin
+	LastRuleNotify map[string]map[string]int64 `json:"lastrulenotify"`
+}
+
+// CorruptError is returned when the state file can not be decoded,
+// the file is moved to Backup and an empty snapshot is used
+type CorruptError struct {
+	Path   string
+	Backup string
+	Err    error
+}
+
+func (e *CorruptError) Error() string {
+	return fmt.Sprintf("state file %s is corrupt, moved to %s: %s", e.Path, e.Backup, e.Err)
+}
+
+func NewSnapshot() *Snapshot {
+	return &Snapshot{
+		LastNotifyTime:  make(map[string]int64),
+		LastRecord:      make(map[string]float32),
+		LastAlertRecord: make(map[string]float32),
+		LastPrice:       make(map[string]price.Value),
+		MuteUntil:       make(map[string]int64),
+		LastRuleNotify:  make(map[string]map[string]int64),
+	}
+}
+
+// Load read the snapshot at path, a missing file give an empty snapshot,
+// a corrupt file give an empty snapshot and a *CorruptError
+func Load(path string) (*Snapshot, error) {
+	snapshot := NewSnapshot()
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return snapshot, nil
+	}
+	if err != nil {
+		return snapshot, err
+	}
+
+	loaded := NewSnapshot()
+	if err := json.Unmarshal(content, loaded); err != nil {
+		backup := fmt.Sprintf("%s.corrupt-%d", path, time.Now().Unix())
+		if rerr := os.Rename(path, backup); rerr != nil {
+			backup = path
+		}
+		return snapshot, &CorruptError{Path: path, Backup: backup, Err: err}
+	}
+
+	// a file with null maps still give usable maps
+	if loaded.LastNotifyTime != nil {
+		snapshot.LastNotifyTime = loaded.LastNotifyTime
+	}
+	if loaded.LastRecord != nil {
+		snapshot.LastRecord = loaded.LastRecord
+	}
+	if loaded.LastAlertRecord != nil {
+		snapshot.LastAlertRecord = loaded.LastAlertRecord
+	}
+	if loaded.LastPrice != nil {
+		snapshot.LastPrice = loaded.LastPrice
+	}
+	if loaded.MuteUntil != nil {
+		snapshot.MuteUntil = loaded.MuteUntil
+	}
+	if loaded.LastRuleNotify != nil {
+		snapshot.LastRuleNotify = loaded.LastRuleNotify
+	}
+	return snapshot, nil
+}
+
+// Save write the snapshot through a temp file and rename, so a crash never
+// leave a half written file behind
+func Save(path string, snapshot *Snapshot) error {
+	content, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFile(path, content)
+}
+
+// WriteFile replace path with content through a temp file so a crash never
+// leave a half written file, the file is only readable by the user
+func WriteFile(path string, content []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Retain drop the state of every coin that keep return false
+func (s *Snapshot) Retain(keep func(coin string) bool) {
+	for coin := range s.LastNotifyTime {
+		if !keep(coin) {
+			delete(s.LastNotifyTime, coin)
+		}
+	}
+	for coin := range s.LastRecord {
+		if !keep(coin) {
+			delete(s.LastRecord, coin)
+		}
+	}
+	for coin := range s.LastAlertRecord {
+		if !keep(coin) {
+			delete(s.LastAlertRecord, coin)
+		}
+	}
+	for coin := range s.LastPrice {
+		if !keep(coin) {
+			delete(s.LastPrice, coin)
+		}
+	}
+	for coin := range s.MuteUntil {
+		if !keep(coin) {
+			delete(s.MuteUntil, coin)
+		}
+	}
+	for coin := range s.LastRuleNotify {
+		if !keep(coin) {
+			delete(s.LastRuleNotify, coin)
+		}
+	}
+}
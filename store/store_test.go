@@ -0,0 +1,67 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smileboywtu/CoinNotify/price"
+)
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sub", "state.json")
+
+	snapshot, err := Load(path)
+	if err != nil || len(snapshot.LastNotifyTime) != 0 {
+		t.Fatal("missing file should give an empty snapshot: ", err)
+	}
+
+	snapshot.LastNotifyTime["CMT"] = 100
+	snapshot.LastNotifyTime["ETH"] = 200
+	snapshot.LastRecord["CMT"] = 5.2
+	snapshot.LastPrice["CMT"] = price.Value{Amount: 0.52, Currency: "CNY"}
+	if err := Save(path, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.Retain(func(coin string) bool { return coin == "CMT" })
+	if len(loaded.LastNotifyTime) != 1 || loaded.LastNotifyTime["CMT"] != 100 ||
+		loaded.LastRecord["CMT"] != 5.2 || loaded.LastPrice["CMT"].Currency != "CNY" {
+		t.Fatal("bad loaded snapshot: ", loaded)
+	}
+}
+
+func TestLoadCorrupt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+	ioutil.WriteFile(path, []byte(`{"lastnotifytime": {"CMT": `), 0600)
+
+	snapshot, err := Load(path)
+	corrupt, ok := err.(*CorruptError)
+	if !ok {
+		t.Fatal("expect CorruptError, got ", err)
+	}
+	if snapshot == nil || snapshot.LastNotifyTime == nil {
+		t.Fatal("corrupt file should still give an empty snapshot")
+	}
+	if _, err := os.Stat(corrupt.Backup); err != nil {
+		t.Fatal("corrupt file should be kept aside: ", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("corrupt file should be moved away")
+	}
+}
@@ -0,0 +1,247 @@
+// Package telegram send alerts through a telegram bot and answer chat commands
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/notify"
+)
+
+// DefaultBaseURL is the official bot api endpoint
+const DefaultBaseURL = "https://api.telegram.org"
+
+type TelegramOpt struct {
+	Token string
+
+	// ChatIDs receive alerts, commands from other chats are ignored
+	ChatIDs []string
+
+	// BaseURL allow a local fake api server in tests
+	BaseURL     string
+	PollTimeout time.Duration
+}
+
+// Controller is the notifier state the chat commands read and change
+type Controller interface {
+	// LastPercent return the last percent seen for coin
+	LastPercent(coin string) (float32, bool)
+
+	// Mute silence coin until the given time, zero time unmute it
+	Mute(coin string, until time.Time)
+
+	// Status describe the running notifier
+	Status() string
+}
+
+type Bot struct {
+	opts   TelegramOpt
+	client *http.Client
+}
+
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+type Update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *Message `json:"message"`
+}
+
+type Message struct {
+	MessageID int64  `json:"message_id"`
+	Text      string `json:"text"`
+	Chat      struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+}
+
+func NewBot(opts TelegramOpt) *Bot {
+	if opts.BaseURL == "" {
+		opts.BaseURL = DefaultBaseURL
+	}
+	if opts.PollTimeout == 0 {
+		opts.PollTimeout = 30 * time.Second
+	}
+	return &Bot{
+		opts: opts,
+		// leave room for the long poll
+		client: &http.Client{Timeout: opts.PollTimeout + 10*time.Second},
+	}
+}
+
+func (b *Bot) call(method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/bot%s/%s", strings.TrimRight(b.opts.BaseURL, "/"), b.opts.Token, method)
+	response, err := b.client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// the url contains the token, keep it out of logs
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return fmt.Errorf("telegram %s http error: %s", method, err)
+	}
+	defer response.Body.Close()
+
+	var apiResult apiResponse
+	if err := json.NewDecoder(response.Body).Decode(&apiResult); err != nil {
+		return fmt.Errorf("telegram %s decode error: %s", method, err)
+	}
+	if !apiResult.OK {
+		return fmt.Errorf("telegram %s fails: %s", method, apiResult.Description)
+	}
+	if result != nil {
+		return json.Unmarshal(apiResult.Result, result)
+	}
+	return nil
+}
+
+// SendMessage send text to one chat
+func (b *Bot) SendMessage(chatID string, text string) error {
+	return b.call("sendMessage", map[string]interface{}{
+		"chat_id": chatID,
+		"text":    text,
+	}, nil)
+}
+
+// FormatAlert render alert as chat text
+func FormatAlert(alert notify.Alert) string {
+	if alert.Warning() {
+		return fmt.Sprintf("warning from %s about %s\n%s", alert.Platform, alert.CoinType, alert.Reason)
+	}
+	text := fmt.Sprintf("%s on %s\nprice: %s\npercent: %s", alert.CoinType, alert.Platform, alert.Price, alert.Percent)
+	if alert.Reason != "" {
+		text += "\nreason: " + alert.Reason
+	}
+	return text
+}
+
+// Notify send alert to every configured chat
+func (b *Bot) Notify(alert notify.Alert) error {
+	if b.opts.Token == "" || len(b.opts.ChatIDs) == 0 {
+		return fmt.Errorf("telegram token or chat id is empty")
+	}
+	for _, chatID := range b.opts.ChatIDs {
+		if err := b.SendMessage(chatID, FormatAlert(alert)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUpdates long poll the updates after offset
+func (b *Bot) GetUpdates(offset int64) ([]Update, error) {
+	var updates []Update
+	err := b.call("getUpdates", map[string]interface{}{
+		"offset":          offset,
+		"timeout":         int(b.opts.PollTimeout / time.Second),
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	return updates, err
+}
+
+func (b *Bot) allowed(chatID int64) bool {
+	id := strconv.FormatInt(chatID, 10)
+	for _, allowed := range b.opts.ChatIDs {
+		if allowed == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Run poll chat commands until quit is closed, errors are sent to errc
+func (b *Bot) Run(controller Controller, quit chan struct{}, errc chan error) {
+	var offset int64
+	for {
+		select {
+		case <-quit:
+			return
+		default:
+		}
+
+		updates, err := b.GetUpdates(offset)
+		if err != nil {
+			errc <- err
+			select {
+			case <-quit:
+				return
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			if update.Message == nil || !b.allowed(update.Message.Chat.ID) {
+				continue
+			}
+			reply := HandleCommand(controller, update.Message.Text, time.Now())
+			if reply == "" {
+				continue
+			}
+			if err := b.SendMessage(strconv.FormatInt(update.Message.Chat.ID, 10), reply); err != nil {
+				errc <- err
+			}
+		}
+	}
+}
+
+// HandleCommand run one chat command and return the reply, empty for non command text
+func HandleCommand(controller Controller, text string, now time.Time) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return ""
+	}
+
+	// in groups the command looks like /price@SomeBot
+	command := strings.SplitN(fields[0], "@", 2)[0]
+	args := fields[1:]
+
+	switch command {
+	case "/price":
+		if len(args) != 1 {
+			return "usage: /price COIN"
+		}
+		coin := strings.ToUpper(args[0])
+		percent, ok := controller.LastPercent(coin)
+		if !ok {
+			return fmt.Sprintf("%s has no record yet", coin)
+		}
+		return fmt.Sprintf("%s last percent: %.2f%%", coin, percent)
+	case "/mute":
+		if len(args) != 2 {
+			return "usage: /mute COIN DURATION, e.g. /mute IOST 2h"
+		}
+		coin := strings.ToUpper(args[0])
+		duration, err := time.ParseDuration(args[1])
+		if err != nil || duration <= 0 {
+			return fmt.Sprintf("invalid duration: %s", args[1])
+		}
+		until := now.Add(duration)
+		controller.Mute(coin, until)
+		return fmt.Sprintf("%s muted until %s", coin, until.Format("2006-01-02 15:04:05"))
+	case "/unmute":
+		if len(args) != 1 {
+			return "usage: /unmute COIN"
+		}
+		coin := strings.ToUpper(args[0])
+		controller.Mute(coin, time.Time{})
+		return fmt.Sprintf("%s unmuted", coin)
+	case "/status":
+		return controller.Status()
+	}
+	return "commands: /price COIN, /mute COIN DURATION, /unmute COIN, /status"
+}
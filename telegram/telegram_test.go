@@ -0,0 +1,132 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/notify"
+)
+
+type fakeController struct {
+	lock  sync.Mutex
+	muted map[string]time.Time
+}
+
+func (f *fakeController) LastPercent(coin string) (float32, bool) {
+	if coin == "CMT" {
+		return 5.2, true
+	}
+	return 0, false
+}
+
+func (f *fakeController) Mute(coin string, until time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.muted[coin] = until
+}
+
+func (f *fakeController) Status() string {
+	return "watching CMT"
+}
+
+// fakeAPI serve getUpdates once and record every sendMessage
+type fakeAPI struct {
+	lock    sync.Mutex
+	polled  bool
+	sent    []map[string]interface{}
+	replied chan struct{}
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var params map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&params)
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/bottoken/getUpdates"):
+		result := "[]"
+		if !f.polled {
+			f.polled = true
+			result = `[
+				{"update_id": 1, "message": {"message_id": 1, "text": "/price@CoinBot cmt", "chat": {"id": 42}}},
+				{"update_id": 2, "message": {"message_id": 2, "text": "/mute IOST 2h", "chat": {"id": 42}}},
+				{"update_id": 3, "message": {"message_id": 3, "text": "/status", "chat": {"id": 7}}}
+			]`
+		} else {
+			time.Sleep(10 * time.Millisecond)
+		}
+		fmt.Fprintf(w, `{"ok": true, "result": %s}`, result)
+	case strings.HasSuffix(r.URL.Path, "/bottoken/sendMessage"):
+		f.sent = append(f.sent, params)
+		if len(f.sent) == 2 {
+			close(f.replied)
+		}
+		fmt.Fprint(w, `{"ok": true, "result": {}}`)
+	default:
+		fmt.Fprint(w, `{"ok": false, "description": "Not Found"}`)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	api := &fakeAPI{replied: make(chan struct{})}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	bot := NewBot(TelegramOpt{Token: "token", ChatIDs: []string{"42", "43"}, BaseURL: server.URL})
+	if err := bot.Notify(notify.Alert{CoinType: "CMT", Platform: "Bittrex", Price: "0.52", Percent: "5.2%"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.sent) != 2 || api.sent[1]["chat_id"] != "43" {
+		t.Fatal("alert should go to every chat: ", api.sent)
+	}
+
+	bad := NewBot(TelegramOpt{Token: "wrong", ChatIDs: []string{"42"}, BaseURL: server.URL})
+	if err := bad.Notify(notify.Alert{CoinType: "CMT"}); err == nil {
+		t.Fatal("api error should be reported")
+	}
+}
+
+func TestRunCommands(t *testing.T) {
+	api := &fakeAPI{replied: make(chan struct{})}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	controller := &fakeController{muted: make(map[string]time.Time)}
+	bot := NewBot(TelegramOpt{Token: "token", ChatIDs: []string{"42"}, BaseURL: server.URL, PollTimeout: time.Second})
+
+	quit := make(chan struct{})
+	errc := make(chan error, 10)
+	go bot.Run(controller, quit, errc)
+
+	select {
+	case <-api.replied:
+	case err := <-errc:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("bot did not reply")
+	}
+	close(quit)
+
+	api.lock.Lock()
+	defer api.lock.Unlock()
+	if len(api.sent) != 2 {
+		t.Fatal("only the allowed chat should get replies: ", api.sent)
+	}
+	if !strings.Contains(api.sent[0]["text"].(string), "5.20%") {
+		t.Fatal("bad price reply: ", api.sent[0])
+	}
+
+	controller.lock.Lock()
+	defer controller.lock.Unlock()
+	if until, ok := controller.muted["IOST"]; !ok || time.Until(until) < time.Hour {
+		t.Fatal("IOST should be muted for 2h: ", controller.muted)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli"
+	"github.com/yudai/gotty/pkg/homedir"
+	"github.com/smileboywtu/CoinNotify/feixiaohao"
+)
+
+// watchlistCommand manage the feixiaohao account watchlist, the coins an
+// empty cointype watch
+func watchlistCommand(load func(c *cli.Context) *AppConfigOpt) cli.Command {
+	open := func(c *cli.Context) *feixiaohao.Watchlist {
+		config := load(c)
+		sessionfile := config.SessionFile
+		if sessionfile != "" {
+			sessionfile = homedir.Expand(sessionfile)
+		}
+		return feixiaohao.NewWatchlist(feixiaohao.NewSession(feixiaohao.UserLoginMeta{
+			UserID: config.UserName,
+			PassWD: config.PassWD,
+		}, sessionfile))
+	}
+
+	return cli.Command{
+		Name:  "watchlist",
+		Usage: "List or edit the feixiaohao account watchlist",
+		Subcommands: []cli.Command{
+			{
+				Name:  "list",
+				Usage: "List the watched coins",
+				Action: func(c *cli.Context) {
+					entries, err := open(c).List()
+					if err != nil {
+						exit(err, 1)
+					}
+					for _, entry := range entries {
+						fmt.Printf("%-10s %-12s %s\n", entry.Symbol, entry.Platform, entry.Code)
+					}
+				},
+			},
+			{
+				Name:      "add",
+				Usage:     "Add a coin to the watchlist",
+				ArgsUsage: "SYMBOL [PLATFORM]",
+				Action: func(c *cli.Context) {
+					if len(c.Args()) < 1 {
+						exit(fmt.Errorf("usage: watchlist add SYMBOL [PLATFORM]"), 1)
+					}
+					if err := open(c).Add(c.Args().Get(0), c.Args().Get(1)); err != nil {
+						exit(err, 1)
+					}
+				},
+			},
+			{
+				Name:      "remove",
+				Usage:     "Remove a coin from the watchlist",
+				ArgsUsage: "SYMBOL [PLATFORM]",
+				Action: func(c *cli.Context) {
+					if len(c.Args()) < 1 {
+						exit(fmt.Errorf("usage: watchlist remove SYMBOL [PLATFORM]"), 1)
+					}
+					if err := open(c).Remove(c.Args().Get(0), c.Args().Get(1)); err != nil {
+						exit(err, 1)
+					}
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,173 @@
+// Package webhook post alerts to a http endpoint
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/notify"
+)
+
+// DefaultTemplate render the alert as a flat json object
+const DefaultTemplate = `{"kind":{{json .Kind}},"platform":{{json .Platform}},"cointype":{{json .CoinType}},"price":{{json .Price}},"percent":{{json .Percent}},"reason":{{json .Reason}}}`
+
+// DefaultSignatureHeader carry the body signature when a secret is set
+const DefaultSignatureHeader = "X-Signature"
+
+type WebhookOpt struct {
+	URL    string
+	Method string
+
+	// Template is a text/template rendered with notify.Alert,
+	// use {{json .Field}} to get a quoted json string
+	Template    string
+	ContentType string
+	Headers     map[string]string
+
+	// Secret enable the "sha256=<hex hmac of body>" signature header
+	Secret          string
+	SignatureHeader string
+
+	// MaxRetries is the extra attempts on 5xx or network error
+	MaxRetries int
+	RetryWait  time.Duration
+	Timeout    time.Duration
+}
+
+// Notifier post every alert to the webhook url
+type Notifier struct {
+	opts   WebhookOpt
+	tmpl   *template.Template
+	client *http.Client
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// NewNotifier check the options and parse the body template
+func NewNotifier(opts WebhookOpt) (*Notifier, error) {
+	if opts.Method == "" {
+		opts.Method = http.MethodPost
+	}
+	if opts.Template == "" {
+		opts.Template = DefaultTemplate
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = "application/json"
+	}
+	if opts.SignatureHeader == "" {
+		opts.SignatureHeader = DefaultSignatureHeader
+	}
+	if opts.RetryWait == 0 {
+		opts.RetryWait = time.Second
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	tmpl, err := template.New("webhook").Funcs(templateFuncs).Parse(opts.Template)
+	if err != nil {
+		return nil, fmt.Errorf("parse webhook template error: %s", err)
+	}
+
+	return &Notifier{
+		opts:   opts,
+		tmpl:   tmpl,
+		client: &http.Client{Timeout: opts.Timeout},
+	}, nil
+}
+
+// Render return the request body for alert
+func (n *Notifier) Render(alert notify.Alert) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := n.tmpl.Execute(&buf, alert); err != nil {
+		return nil, fmt.Errorf("render webhook template error: %s", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Sign return the signature header value of body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (n *Notifier) Notify(alert notify.Alert) error {
+	if n.opts.URL == "" {
+		return fmt.Errorf("webhook url is empty")
+	}
+
+	body, err := n.Render(alert)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, err := n.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= n.opts.MaxRetries {
+			return err
+		}
+		time.Sleep(n.opts.RetryWait * time.Duration(attempt+1))
+	}
+}
+
+// post send body once, tell the caller whether the failure is worth a retry
+func (n *Notifier) post(body []byte) (bool, error) {
+	request, err := http.NewRequest(n.opts.Method, n.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("create webhook request error: %s", err)
+	}
+	request.Header.Set("Content-Type", n.opts.ContentType)
+	for key, value := range n.opts.Headers {
+		request.Header.Set(key, value)
+	}
+	if n.opts.Secret != "" {
+		request.Header.Set(n.opts.SignatureHeader, Sign(n.opts.Secret, body))
+	}
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return true, fmt.Errorf("webhook http error: %s", err)
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode >= 500 {
+		return true, fmt.Errorf("webhook server error: %s", response.Status)
+	}
+	if response.StatusCode >= 300 {
+		return false, fmt.Errorf("webhook rejected: %s", response.Status)
+	}
+	return false, nil
+}
+
+// ParseHeaders convert "Key: Value" lines into a header map
+func ParseHeaders(lines []string) (map[string]string, error) {
+	headers := make(map[string]string, len(lines))
+	for _, line := range lines {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid webhook header: %s, use Key: Value", line)
+		}
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return headers, nil
+}
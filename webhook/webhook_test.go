@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/notify"
+)
+
+func TestNotify(t *testing.T) {
+	alert := notify.Alert{
+		CoinType: "CMT",
+		Platform: "Bittrex",
+		Price:    "¥0.52",
+		Percent:  "5.2%",
+		Reason:   "first \"notify\"",
+	}
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// first attempt fails to test retry
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Token") != "abc" {
+			t.Error("custom header missing: ", r.Header)
+		}
+		if r.Header.Get(DefaultSignatureHeader) != Sign("secret", body) {
+			t.Error("bad signature: ", r.Header.Get(DefaultSignatureHeader))
+		}
+
+		var got notify.Alert
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Error("body is not json: ", string(body))
+		}
+		if got != alert {
+			t.Error("body mismatch: ", got)
+		}
+	}))
+	defer server.Close()
+
+	notifier, err := NewNotifier(WebhookOpt{
+		URL:        server.URL,
+		Headers:    map[string]string{"X-Token": "abc"},
+		Secret:     "secret",
+		MaxRetries: 2,
+		RetryWait:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := notifier.Notify(alert); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatal("expect one retry, calls: ", calls)
+	}
+}
+
+func TestNotifyNoRetryOnClientError(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	notifier, _ := NewNotifier(WebhookOpt{URL: server.URL, MaxRetries: 3, RetryWait: time.Millisecond})
+	if err := notifier.Notify(notify.Alert{CoinType: "CMT"}); err == nil {
+		t.Fatal("4xx should fail")
+	}
+	if calls != 1 {
+		t.Fatal("4xx should not retry, calls: ", calls)
+	}
+}
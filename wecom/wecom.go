@@ -0,0 +1,105 @@
+// Package wecom send alerts to a wecom (企业微信) group robot
+package wecom
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/smileboywtu/CoinNotify/notify"
+)
+
+const (
+	MsgTypeText     = "text"
+	MsgTypeMarkdown = "markdown"
+)
+
+type RobotOpt struct {
+	// Webhook is the robot url with key
+	Webhook string
+	MsgType string
+}
+
+type Robot struct {
+	opts   RobotOpt
+	client *http.Client
+}
+
+type robotResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func NewRobot(opts RobotOpt) *Robot {
+	if opts.MsgType == "" {
+		opts.MsgType = MsgTypeText
+	}
+	return &Robot{
+		opts:   opts,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Message build the robot message of alert
+func (r *Robot) Message(alert notify.Alert) map[string]interface{} {
+	if alert.Warning() {
+		return map[string]interface{}{
+			"msgtype": MsgTypeText,
+			"text":    map[string]string{"content": fmt.Sprintf("价格源%s告警，币种：%s，%s", alert.Platform, alert.CoinType, alert.Reason)},
+		}
+	}
+	if r.opts.MsgType == MsgTypeMarkdown {
+		content := fmt.Sprintf("**%s 价格提醒**\n>交易平台: %s\n>当前价格: %s\n>浮动: <font color=\"warning\">%s</font>\n",
+			alert.CoinType, alert.Platform, alert.Price, alert.Percent)
+		if alert.Reason != "" {
+			content += fmt.Sprintf(">原因: %s\n", alert.Reason)
+		}
+		return map[string]interface{}{
+			"msgtype":  MsgTypeMarkdown,
+			"markdown": map[string]string{"content": content},
+		}
+	}
+
+	content := fmt.Sprintf("交易平台%s，币种：%s，当前价格：%s，浮动：%s",
+		alert.Platform, alert.CoinType, alert.Price, alert.Percent)
+	if alert.Reason != "" {
+		content += "，原因：" + alert.Reason
+	}
+	return map[string]interface{}{
+		"msgtype": MsgTypeText,
+		"text":    map[string]string{"content": content},
+	}
+}
+
+func (r *Robot) Notify(alert notify.Alert) error {
+	if r.opts.Webhook == "" {
+		return fmt.Errorf("wecom webhook is empty")
+	}
+
+	body, err := json.Marshal(r.Message(alert))
+	if err != nil {
+		return err
+	}
+
+	response, err := r.client.Post(r.opts.Webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// the url contains the token, keep it out of logs
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return fmt.Errorf("wecom http error: %s", err)
+	}
+	defer response.Body.Close()
+
+	var result robotResponse
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return fmt.Errorf("wecom response error: %s, %s", response.Status, err)
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("wecom send fails: %d %s", result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
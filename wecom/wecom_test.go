@@ -0,0 +1,44 @@
+package wecom
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/smileboywtu/CoinNotify/notify"
+)
+
+func TestNotify(t *testing.T) {
+	var message map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "abc" {
+			fmt.Fprint(w, `{"errcode": 93000, "errmsg": "invalid webhook url"}`)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&message)
+		fmt.Fprint(w, `{"errcode": 0, "errmsg": "ok"}`)
+	}))
+	defer server.Close()
+
+	alert := notify.Alert{CoinType: "IOST", Platform: "Binance", Price: "0.11", Percent: "-3.1%"}
+	if err := NewRobot(RobotOpt{Webhook: server.URL + "?key=abc"}).Notify(alert); err != nil {
+		t.Fatal(err)
+	}
+	text := message["text"].(map[string]interface{})["content"].(string)
+	if message["msgtype"] != MsgTypeText || !strings.Contains(text, "IOST") {
+		t.Fatal("bad text message: ", message)
+	}
+
+	if err := NewRobot(RobotOpt{Webhook: server.URL + "?key=bad"}).Notify(alert); err == nil {
+		t.Fatal("robot error code should be reported")
+	}
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	if err := NewRobot(RobotOpt{Webhook: closed.URL + "?key=abc"}).Notify(alert); err == nil || strings.Contains(err.Error(), "abc") {
+		t.Fatal("http error should not leak the key: ", err)
+	}
+}